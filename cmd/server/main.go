@@ -1,15 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"time"
 
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/api/graphite"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/api/grpc"
 	"github.com/agatma/sprint1-http-server/internal/server/adapters/api/rest"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/api/statsd"
 	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
 	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/webhook"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
 	"github.com/agatma/sprint1-http-server/internal/server/core/service"
 )
 
+const (
+	webhookTimeout    = 10 * time.Second
+	webhookBackoff    = time.Second
+	webhookMaxBackoff = time.Minute
+	webhookQueueSize  = 100
+)
+
 func main() {
 	if err := run(); err != nil {
 		log.Fatal(err)
@@ -21,22 +37,123 @@ func run() error {
 	if err != nil {
 		return fmt.Errorf("can't load config: %w", err)
 	}
-	gaugeStorage, err := storage.NewStorage(storage.Config{
-		Memory: &memory.Config{},
-	})
+	storageCfg := &memory.Config{
+		Retention:  time.Duration(*cfg.HistoryAge) * time.Second,
+		MaxSamples: cfg.HistorySize,
+		Rollups:    memory.DefaultRollups,
+		StaleAfter: time.Duration(*cfg.StaleAfter) * time.Second,
+		TTL:        time.Duration(cfg.MetricTTL) * time.Second,
+		Buckets:    cfg.Buckets,
+	}
+	storages := make(map[string]storage.MetricStorage)
+	for _, metricType := range []string{domain.Gauge, domain.Counter, domain.Histogram, domain.Summary, domain.Set} {
+		if storages[metricType], err = storage.NewStorage(storage.Config{Memory: storageCfg}); err != nil {
+			return fmt.Errorf("failed to initialize %s storage: %w", metricType, err)
+		}
+	}
+	metricService := service.NewMetricService(
+		storages[domain.Gauge],
+		storages[domain.Counter],
+		service.WithHistogramStorage(storages[domain.Histogram]),
+		service.WithSummaryStorage(storages[domain.Summary]),
+		service.WithSetStorage(storages[domain.Set]),
+	)
+	rules, err := loadAlertRules(cfg.AlertRules)
 	if err != nil {
-		return fmt.Errorf("no available storage for server: %w", err)
+		return fmt.Errorf("failed to load alert rules: %w", err)
+	}
+	errs := make(chan error, 1)
+	var alertOpts []service.AlertOption
+	if len(cfg.WebhookURLs) > 0 {
+		sender := webhook.NewSender(&webhook.Config{
+			URLs:       cfg.WebhookURLs,
+			Timeout:    webhookTimeout,
+			Retries:    *cfg.WebhookTries,
+			Backoff:    webhookBackoff,
+			MaxBackoff: webhookMaxBackoff,
+			QueueSize:  webhookQueueSize,
+		})
+		alertOpts = append(alertOpts, service.WithAlertSender(sender, time.Duration(*cfg.WebhookRepeat)*time.Second))
+		go func() {
+			errs <- sender.Run(context.Background())
+		}()
 	}
-	counterStorage, err := storage.NewStorage(storage.Config{
-		Memory: &memory.Config{},
-	})
+	alertService := service.NewAlertService(metricService, rules, alertOpts...)
+	api, err := rest.NewAPI(metricService, cfg, rest.WithAlertService(alertService))
 	if err != nil {
-		return fmt.Errorf("failed to initialize a storage: %w", err)
+		return fmt.Errorf("failed to initialize api: %w", err)
+	}
+	if len(rules) > 0 {
+		go func() {
+			errs <- alertService.RunEvaluation(context.Background(), time.Duration(cfg.AlertEvery)*time.Second)
+		}()
+	}
+	if *cfg.RollupEvery > 0 {
+		go func() {
+			errs <- metricService.RunRollups(context.Background(), time.Duration(*cfg.RollupEvery)*time.Second)
+		}()
+	}
+	if cfg.MetricTTL > 0 {
+		go func() {
+			errs <- metricService.RunJanitor(context.Background(), time.Duration(cfg.JanitorEvery)*time.Second)
+		}()
+	}
+	if cfg.GRPCAddress != "" {
+		if cfg.CryptoKey != "" {
+			return errors.New("crypto key is not supported by the grpc transport")
+		}
+		grpcAPI, err := grpc.NewAPI(metricService, &grpc.Config{
+			Address:       cfg.GRPCAddress,
+			TrustedSubnet: cfg.TrustedSubnet,
+			Key:           cfg.Key,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to initialize grpc api: %w", err)
+		}
+		go func() {
+			errs <- grpcAPI.Run()
+		}()
 	}
-	metricService := service.NewMetricService(gaugeStorage, counterStorage)
-	api := rest.NewAPI(metricService, cfg)
-	if err := api.Run(); err != nil {
+	if cfg.StatsDAddress != "" {
+		listener := statsd.NewListener(metricService, &statsd.Config{
+			Address:       cfg.StatsDAddress,
+			FlushInterval: time.Duration(cfg.StatsDFlush) * time.Second,
+		})
+		go func() {
+			errs <- listener.Run()
+		}()
+	}
+	if cfg.GraphiteAddr != "" {
+		listener := graphite.NewListener(metricService, &graphite.Config{
+			Address:     cfg.GraphiteAddr,
+			IdleTimeout: time.Duration(cfg.GraphiteIdle) * time.Second,
+			MaxLines:    cfg.GraphiteLines,
+		})
+		go func() {
+			errs <- listener.Run()
+		}()
+	}
+	go func() {
+		errs <- api.Run()
+	}()
+	if err := <-errs; err != nil {
 		return fmt.Errorf("server has failed: %w", err)
 	}
 	return nil
 }
+
+// loadAlertRules reads the alert rules file, there are no rules without one.
+func loadAlertRules(path string) ([]*domain.AlertRule, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	rules, err := domain.ParseAlertRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return rules, nil
+}
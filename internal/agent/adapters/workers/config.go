@@ -16,6 +16,9 @@ type Config struct {
 	Address        string `env:"ADDRESS"`
 	ReportInterval int    `env:"REPORT_INTERVAL"`
 	PollInterval   int    `env:"POLL_INTERVAL"`
+	Key            string `env:"KEY"`
+	CryptoKey      string `env:"CRYPTO_KEY"`
+	GRPCAddress    string `env:"GRPC_ADDRESS"`
 }
 
 func NewConfig() (*Config, error) {
@@ -24,10 +27,16 @@ func NewConfig() (*Config, error) {
 		flagRunAddr    *string
 		pollInterval   *int
 		reportInterval *int
+		key            *string
+		cryptoKey      *string
+		grpcAddress    *string
 	)
 	flagRunAddr = flag.String("a", "localhost:8080", "run address")
 	pollInterval = flag.Int("p", defaultPollInterval, " poll interval ")
 	reportInterval = flag.Int("r", defaultReportInterval, " report interval ")
+	key = flag.String("k", "", "key to sign request bodies with HMAC-SHA256")
+	cryptoKey = flag.String("crypto-key", "", "path to the server public key to encrypt request bodies")
+	grpcAddress = flag.String("g", "", "grpc server address, metrics are sent over grpc when set")
 	flag.Parse()
 	err := env.Parse(&cfg)
 	if err != nil {
@@ -43,5 +52,14 @@ func NewConfig() (*Config, error) {
 	if cfg.PollInterval == 0 {
 		cfg.PollInterval = *pollInterval
 	}
+	if cfg.Key == "" {
+		cfg.Key = *key
+	}
+	if cfg.CryptoKey == "" {
+		cfg.CryptoKey = *cryptoKey
+	}
+	if cfg.GRPCAddress == "" {
+		cfg.GRPCAddress = *grpcAddress
+	}
 	return &cfg, nil
 }
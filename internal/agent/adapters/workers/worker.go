@@ -1,14 +1,17 @@
 package workers
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/agatma/sprint1-http-server/internal/agent/core/domain"
 )
 
 type AgentMetricService interface {
 	UpdateMetrics(pollCount int) error
-	SendMetrics(host string) error
+	SendMetrics(request *domain.SendMetricsRequest) error
 }
 
 type AgentWorker struct {
@@ -30,6 +33,21 @@ func (a *AgentWorker) Run() error {
 		port = address[1]
 	}
 	host := "http://localhost:" + port
+	request := &domain.SendMetricsRequest{
+		Host:        host,
+		GRPCAddress: a.config.GRPCAddress,
+		Key:         a.config.Key,
+	}
+	if a.config.CryptoKey != "" {
+		if a.config.GRPCAddress != "" {
+			return errors.New("crypto key is not supported by the grpc transport")
+		}
+		publicKey, err := loadPublicKey(a.config.CryptoKey)
+		if err != nil {
+			return fmt.Errorf("failed to load crypto key %w", err)
+		}
+		request.PublicKey = publicKey
+	}
 	updateMetricsTicker := time.NewTicker(time.Duration(a.config.PollInterval) * time.Second)
 	sendMetricsTicker := time.NewTicker(time.Duration(a.config.ReportInterval) * time.Second)
 	pollCount := 0
@@ -42,7 +60,7 @@ func (a *AgentWorker) Run() error {
 				return fmt.Errorf("failed to update metrics %w", err)
 			}
 		case <-sendMetricsTicker.C:
-			err := a.agentMetricService.SendMetrics(host)
+			err := a.agentMetricService.SendMetrics(request)
 			if err != nil {
 				return fmt.Errorf("failed to send metrics %w", err)
 			}
@@ -1,5 +1,7 @@
 package domain
 
+import "crypto/rsa"
+
 const (
 	Gauge       = "gauge"
 	Counter     = "counter"
@@ -39,3 +41,17 @@ type GetAllMetricsResponse struct {
 	Values map[string]string
 	Error  error
 }
+
+type Metric struct {
+	ID    string   `json:"id"`
+	MType string   `json:"type"`
+	Delta *int64   `json:"delta,omitempty"`
+	Value *float64 `json:"value,omitempty"`
+}
+
+type SendMetricsRequest struct {
+	Host        string
+	GRPCAddress string
+	Key         string
+	PublicKey   *rsa.PublicKey
+}
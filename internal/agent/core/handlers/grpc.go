@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/agatma/sprint1-http-server/internal/agent/core/domain"
+	pb "github.com/agatma/sprint1-http-server/internal/proto"
+)
+
+// grpcHashKey is the metadata counterpart of the HashSHA256 header.
+const grpcHashKey = "hashsha256"
+
+// SendMetricsGRPC streams metrics to the gRPC server as a single batch. With
+// a key the batch is signed in the hashsha256 metadata. Encryption only
+// applies to the HTTP transport.
+func SendMetricsGRPC(request *domain.SendMetricsRequest, metrics []domain.Metric) error {
+	conn, err := grpc.NewClient(request.GRPCAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("failed to create grpc client: %w", err)
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("failed to close grpc connection: %v", err)
+		}
+	}()
+	ctx := context.Background()
+	if ip, err := outboundIP("grpc://" + request.GRPCAddress); err != nil {
+		log.Printf("failed to detect outbound address: %v", err)
+	} else {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-real-ip", ip.String())
+	}
+	batch := &pb.UpdateMetricsRequest{
+		Metrics: make([]*pb.Metric, 0, len(metrics)),
+	}
+	for _, metric := range metrics {
+		batch.Metrics = append(batch.Metrics, toProto(metric))
+	}
+	if request.Key != "" {
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(batch)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metrics: %w", err)
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, grpcHashKey, sign(data, request.Key))
+	}
+	stream, err := pb.NewMetricsClient(conn).UpdateMetrics(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open grpc stream: %w", err)
+	}
+	if err = stream.Send(batch); err != nil {
+		return fmt.Errorf("failed to send metrics: %w", err)
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return fmt.Errorf("failed to send metrics: %w", err)
+	}
+	log.Printf("sent %d metrics to grpc server %s", resp.GetUpdated(), request.GRPCAddress)
+	return nil
+}
+
+func toProto(metric domain.Metric) *pb.Metric {
+	m := &pb.Metric{Id: metric.ID}
+	switch {
+	case metric.MType == domain.Gauge && metric.Value != nil:
+		m.Type = pb.Metric_GAUGE
+		m.Value = &pb.Metric_Gauge{Gauge: *metric.Value}
+	case metric.MType == domain.Counter && metric.Delta != nil:
+		m.Type = pb.Metric_COUNTER
+		m.Value = &pb.Metric_Delta{Delta: *metric.Delta}
+	}
+	return m
+}
@@ -1,23 +1,57 @@
 package handlers
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"strings"
+	"net/url"
 
 	"github.com/go-resty/resty/v2"
+
+	"github.com/agatma/sprint1-http-server/internal/agent/core/domain"
+)
+
+const (
+	hashHeader   = "HashSHA256"
+	realIPHeader = "X-Real-IP"
 )
 
-func SendMetrics(host string, metricType string, metricName string, metricValue string) error {
+func SendMetrics(request *domain.SendMetricsRequest, metrics []domain.Metric) error {
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metrics: %w", err)
+	}
+	body, err := compress(data)
+	if err != nil {
+		return fmt.Errorf("failed to compress metrics: %w", err)
+	}
+	if request.PublicKey != nil {
+		if body, err = encrypt(body, request.PublicKey); err != nil {
+			return fmt.Errorf("failed to encrypt metrics: %w", err)
+		}
+	}
 	client := resty.New()
-	resp, err := client.R().
-		SetRawPathParams(map[string]string{
-			"metricType":  metricType,
-			"metricName":  strings.ToLower(metricName),
-			"metricValue": metricValue,
-		}).
-		Post(host + "/update/{metricType}/{metricName}/{metricValue}")
+	req := client.R().
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Content-Encoding", "gzip").
+		SetBody(body)
+	if request.Key != "" {
+		req.SetHeader(hashHeader, sign(data, request.Key))
+	}
+	if ip, err := outboundIP(request.Host); err != nil {
+		log.Printf("failed to detect outbound address: %v", err)
+	} else {
+		req.SetHeader(realIPHeader, ip.String())
+	}
+	resp, err := req.Post(request.Host + "/updates/")
 
 	if err != nil {
 		return fmt.Errorf("failed to send metrics: %w", err)
@@ -27,6 +61,56 @@ func SendMetrics(host string, metricType string, metricName string, metricValue
 		return fmt.Errorf("bad request. Status Code %d", resp.StatusCode())
 	}
 
-	log.Printf("made request %s. Got status code %d", resp.Request.URL, resp.StatusCode())
+	if request.Key != "" && !hmac.Equal([]byte(resp.Header().Get(hashHeader)), []byte(sign(resp.Body(), request.Key))) {
+		return errors.New("response signature mismatch")
+	}
+
+	log.Printf("made request %s with %d metrics. Got status code %d", resp.Request.URL, len(metrics), resp.StatusCode())
 	return nil
 }
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write gzip body: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// sign returns the hex encoded HMAC-SHA256 of the uncompressed payload.
+func sign(data []byte, key string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// outboundIP returns the local address the system would use to reach host.
+// Dialing UDP only picks a route, no packets are sent.
+func outboundIP(host string) (net.IP, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse host: %w", err)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial host: %w", err)
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("failed to close connection: %v", err)
+		}
+	}()
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil, errors.New("unexpected local address type")
+	}
+	return addr.IP, nil
+}
@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"runtime"
 	"strconv"
+	"strings"
 
 	"github.com/agatma/sprint1-http-server/internal/agent/core/domain"
 	"github.com/agatma/sprint1-http-server/internal/agent/core/handlers"
@@ -113,27 +114,48 @@ func (a *AgentMetricService) getAllMetrics(request *domain.GetAllMetricsRequest)
 	}
 }
 
-func (a *AgentMetricService) SendMetrics(host string) error {
-	response := a.getAllMetrics(&domain.GetAllMetricsRequest{
+func (a *AgentMetricService) SendMetrics(request *domain.SendMetricsRequest) error {
+	gauges := a.getAllMetrics(&domain.GetAllMetricsRequest{
 		MetricType: domain.Gauge,
 	})
-	for metricName, metricValue := range response.Values {
-		err := handlers.SendMetrics(host, domain.Gauge, metricName, metricValue)
-		if err != nil {
-			return fmt.Errorf("error occured during sending metrics: %w", err)
-		}
+	if gauges.Error != nil {
+		return fmt.Errorf("error occured geting metrics: %w", gauges.Error)
 	}
-	response = a.getAllMetrics(&domain.GetAllMetricsRequest{
+	counters := a.getAllMetrics(&domain.GetAllMetricsRequest{
 		MetricType: domain.Counter,
 	})
-	if response.Error != nil {
-		return fmt.Errorf("error occured geting metrics: %w", response.Error)
+	if counters.Error != nil {
+		return fmt.Errorf("error occured geting metrics: %w", counters.Error)
+	}
+	metrics := make([]domain.Metric, 0, len(gauges.Values)+len(counters.Values))
+	for metricName, metricValue := range gauges.Values {
+		value, err := strconv.ParseFloat(metricValue, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse gauge %s: %w", metricName, err)
+		}
+		metrics = append(metrics, domain.Metric{
+			ID:    strings.ToLower(metricName),
+			MType: domain.Gauge,
+			Value: &value,
+		})
 	}
-	for metricName, metricValue := range response.Values {
-		err := handlers.SendMetrics(host, domain.Counter, metricName, metricValue)
+	for metricName, metricValue := range counters.Values {
+		delta, err := strconv.ParseInt(metricValue, 10, 64)
 		if err != nil {
-			return fmt.Errorf("error occured during sending metrics: %w", err)
+			return fmt.Errorf("failed to parse counter %s: %w", metricName, err)
 		}
+		metrics = append(metrics, domain.Metric{
+			ID:    strings.ToLower(metricName),
+			MType: domain.Counter,
+			Delta: &delta,
+		})
+	}
+	send := handlers.SendMetrics
+	if request.GRPCAddress != "" {
+		send = handlers.SendMetricsGRPC
+	}
+	if err := send(request, metrics); err != nil {
+		return fmt.Errorf("error occured during sending metrics: %w", err)
 	}
 	return nil
 }
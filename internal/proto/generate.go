@@ -0,0 +1,4 @@
+// Package proto holds the gRPC contract shared by the agent and the server.
+package proto
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative metrics.proto
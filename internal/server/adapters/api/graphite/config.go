@@ -0,0 +1,11 @@
+package graphite
+
+import "time"
+
+type Config struct {
+	Address     string
+	IdleTimeout time.Duration
+	// MaxLines is the number of lines a single connection may send before it
+	// is closed.
+	MaxLines int
+}
@@ -0,0 +1,159 @@
+package graphite
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+const maxLineLength = 4096
+
+var (
+	errInvalidLine = errors.New("invalid graphite line")
+	errBadTag      = errors.New("bad tag")
+)
+
+type MetricService interface {
+	SetMetricValue(request *domain.SetMetricRequest) *domain.SetMetricResponse
+}
+
+// Listener accepts the Graphite plaintext protocol over TCP and stores every
+// "path value timestamp" line as a gauge.
+type Listener struct {
+	metricService MetricService
+	config        *Config
+}
+
+func NewListener(metricService MetricService, cfg *Config) *Listener {
+	return &Listener{
+		metricService: metricService,
+		config:        cfg,
+	}
+}
+
+func (l *Listener) Run() error {
+	if l.config.IdleTimeout <= 0 {
+		return fmt.Errorf("idle timeout must be positive, got %s", l.config.IdleTimeout)
+	}
+	listener, err := net.Listen("tcp", l.config.Address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", l.config.Address, err)
+	}
+	return l.serve(listener)
+}
+
+func (l *Listener) serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to accept graphite connection: %w", err)
+		}
+		go l.handleConn(conn)
+	}
+}
+
+func (l *Listener) handleConn(conn net.Conn) {
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("failed to close graphite connection: %v", err)
+		}
+	}()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, maxLineLength), maxLineLength)
+	lines := 0
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(l.config.IdleTimeout)); err != nil {
+			log.Printf("failed to set graphite read deadline: %v", err)
+			return
+		}
+		if !scanner.Scan() {
+			break
+		}
+		lines++
+		if l.config.MaxLines > 0 && lines > l.config.MaxLines {
+			log.Printf("closing graphite connection from %s: more than %d lines", conn.RemoteAddr(), l.config.MaxLines)
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		request, err := parseLine(line)
+		if err != nil {
+			log.Printf("failed to parse graphite line from %s: %v", conn.RemoteAddr(), err)
+			continue
+		}
+		if response := l.metricService.SetMetricValue(request); response.Error != nil {
+			log.Printf(
+				"failed to set metric value %s for metricName %s: %v",
+				request.MetricValue,
+				request.MetricName,
+				response.Error,
+			)
+		}
+	}
+	var netErr net.Error
+	if err := scanner.Err(); errors.As(err, &netErr) && netErr.Timeout() {
+		log.Printf("closing idle graphite connection from %s", conn.RemoteAddr())
+	} else if err != nil {
+		log.Printf("failed to read graphite connection from %s: %v", conn.RemoteAddr(), err)
+	}
+}
+
+// parseLine reads "path value [timestamp]", where path may carry tags in the
+// Graphite notation name;key=value. The timestamp is validated but not
+// stored, history records samples at the time they are received.
+func parseLine(line string) (*domain.SetMetricRequest, error) {
+	fields := strings.Fields(line)
+	const minFields, maxFields = 2, 3
+	if len(fields) < minFields || len(fields) > maxFields {
+		return nil, fmt.Errorf("%w %q: expected path value timestamp", errInvalidLine, line)
+	}
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: bad value: %w", errInvalidLine, line, err)
+	}
+	if len(fields) == maxFields {
+		if _, err = strconv.ParseFloat(fields[2], 64); err != nil {
+			return nil, fmt.Errorf("%w %q: bad timestamp: %w", errInvalidLine, line, err)
+		}
+	}
+	name, labels, err := parsePath(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", errInvalidLine, line, err)
+	}
+	return &domain.SetMetricRequest{
+		MetricType:  domain.Gauge,
+		MetricName:  name,
+		MetricValue: strconv.FormatFloat(value, 'f', -1, 64),
+		Labels:      labels,
+	}, nil
+}
+
+// parsePath splits a tagged path such as disk.used;host=web01;mount=/ into
+// the metric name and its labels.
+func parsePath(path string) (string, domain.Labels, error) {
+	parts := strings.Split(path, ";")
+	if len(parts) == 1 {
+		return path, nil, nil
+	}
+	labels := make(domain.Labels, len(parts)-1)
+	for _, tag := range parts[1:] {
+		key, value, found := strings.Cut(tag, "=")
+		if !found || key == "" || value == "" {
+			return "", nil, fmt.Errorf("%w %q", errBadTag, tag)
+		}
+		labels[key] = value
+	}
+	return parts[0], labels, nil
+}
@@ -0,0 +1,132 @@
+package graphite
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    *domain.SetMetricRequest
+		wantErr bool
+	}{
+		{
+			name: "withTimestamp",
+			line: "servers.web01.load 1.25 1712345678",
+			want: &domain.SetMetricRequest{MetricType: domain.Gauge, MetricName: "servers.web01.load", MetricValue: "1.25"},
+		},
+		{
+			name: "withoutTimestamp",
+			line: "queue.size 42",
+			want: &domain.SetMetricRequest{MetricType: domain.Gauge, MetricName: "queue.size", MetricValue: "42"},
+		},
+		{
+			name: "withTags",
+			line: "disk.used;host=web01;mount=/ 0.5",
+			want: &domain.SetMetricRequest{
+				MetricType:  domain.Gauge,
+				MetricName:  "disk.used",
+				MetricValue: "0.5",
+				Labels:      domain.Labels{"host": "web01", "mount": "/"},
+			},
+		},
+		{name: "badTag", line: "disk.used;host 0.5", wantErr: true},
+		{name: "missingValue", line: "queue.size", wantErr: true},
+		{name: "badValue", line: "queue.size many 1712345678", wantErr: true},
+		{name: "badTimestamp", line: "queue.size 1 now", wantErr: true},
+		{name: "extraFields", line: "queue.size 1 2 3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLine(tt.line)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func newTestListener(t *testing.T, cfg *Config) (*service.MetricService, string) {
+	t.Helper()
+	gaugeStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	counterStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	metricService := service.NewMetricService(gaugeStorage, counterStorage)
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		done <- NewListener(metricService, cfg).serve(tcpListener)
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, tcpListener.Close())
+		require.NoError(t, <-done)
+	})
+	return metricService, tcpListener.Addr().String()
+}
+
+func gaugeValue(metricService *service.MetricService, name string) string {
+	return metricService.GetMetricValue(&domain.MetricRequest{
+		MetricType: domain.Gauge,
+		MetricName: name,
+	}).MetricValue
+}
+
+func TestListener_StoresGauges(t *testing.T) {
+	metricService, addr := newTestListener(t, &Config{IdleTimeout: time.Second, MaxLines: 10})
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("a.b 1 1712345678\nbroken\na.c 2.5 1712345678\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	assert.Eventually(t, func() bool {
+		return gaugeValue(metricService, "a.c") == "2.5"
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "1", gaugeValue(metricService, "a.b"))
+}
+
+func TestListener_ClosesAfterMaxLines(t *testing.T) {
+	metricService, addr := newTestListener(t, &Config{IdleTimeout: time.Second, MaxLines: 2})
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	_, err = conn.Write([]byte("a 1\nb 2\nc 3\n"))
+	require.NoError(t, err)
+	_, err = bufio.NewReader(conn).ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "2", gaugeValue(metricService, "b"))
+	assert.Empty(t, gaugeValue(metricService, "c"))
+}
+
+func TestListener_ClosesIdleConnections(t *testing.T) {
+	_, addr := newTestListener(t, &Config{IdleTimeout: 50 * time.Millisecond})
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = bufio.NewReader(conn).ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,7 @@
+package grpc
+
+type Config struct {
+	Address       string
+	TrustedSubnet string
+	Key           string
+}
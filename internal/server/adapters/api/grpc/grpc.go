@@ -0,0 +1,348 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/agatma/sprint1-http-server/internal/proto"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+// metricTypes are listed by ListMetrics in this order. Types without a
+// storage come back empty.
+var metricTypes = []string{domain.Gauge, domain.Counter, domain.Histogram, domain.Summary, domain.Set}
+
+type MetricService interface {
+	GetMetricValue(request *domain.MetricRequest) *domain.MetricResponse
+	SetMetricValue(request *domain.SetMetricRequest) *domain.SetMetricResponse
+	SetMetricValues(request *domain.SetMetricsRequest) *domain.SetMetricResponse
+	GetAllMetrics(request *domain.GetAllMetricsRequest) *domain.GetAllMetricsResponse
+}
+
+type handler struct {
+	pb.UnimplementedMetricsServer
+	metricService MetricService
+}
+
+type API struct {
+	srv     *gogrpc.Server
+	address string
+}
+
+func (a *API) Run() error {
+	listener, err := net.Listen("tcp", a.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", a.address, err)
+	}
+	if err = a.srv.Serve(listener); err != nil {
+		log.Printf("error occured during running grpc server %v", err)
+		return fmt.Errorf("failed run grpc server: %w", err)
+	}
+	return nil
+}
+
+func NewAPI(metricService MetricService, cfg *Config) (*API, error) {
+	var trustedSubnet *net.IPNet
+	if cfg.TrustedSubnet != "" {
+		var err error
+		if _, trustedSubnet, err = net.ParseCIDR(cfg.TrustedSubnet); err != nil {
+			return nil, fmt.Errorf("failed to parse trusted subnet: %w", err)
+		}
+	}
+	srv := gogrpc.NewServer(
+		gogrpc.ChainUnaryInterceptor(subnetUnaryInterceptor(trustedSubnet), hashUnaryInterceptor(cfg.Key)),
+		gogrpc.ChainStreamInterceptor(subnetStreamInterceptor(trustedSubnet), hashStreamInterceptor(cfg.Key)),
+	)
+	pb.RegisterMetricsServer(srv, &handler{
+		metricService: metricService,
+	})
+	return &API{
+		srv:     srv,
+		address: cfg.Address,
+	}, nil
+}
+
+func (h *handler) UpdateMetric(_ context.Context, req *pb.UpdateMetricRequest) (*pb.UpdateMetricResponse, error) {
+	request, err := toSetMetricRequest(req.GetMetric())
+	if err == nil {
+		err = h.metricService.SetMetricValue(request).Error
+	}
+	if err != nil {
+		log.Printf("failed to set metric value for metric %s: %v", req.GetMetric().GetId(), err)
+		return nil, toStatus(err)
+	}
+	metric, err := h.getStoredMetric(request.MetricType, request.MetricName, request.Labels)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.UpdateMetricResponse{Metric: metric}, nil
+}
+
+func (h *handler) UpdateMetrics(stream pb.Metrics_UpdateMetricsServer) error {
+	var updated int64
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&pb.UpdateMetricsResponse{Updated: updated}) //nolint:wrapcheck // grpc status
+		}
+		if err != nil {
+			return fmt.Errorf("failed to receive batch: %w", err)
+		}
+		request := &domain.SetMetricsRequest{
+			Metrics: make([]*domain.SetMetricRequest, 0, len(req.GetMetrics())),
+		}
+		for _, metric := range req.GetMetrics() {
+			setRequest, err := toSetMetricRequest(metric)
+			if err != nil {
+				return toStatus(err)
+			}
+			request.Metrics = append(request.Metrics, setRequest)
+		}
+		if err = h.metricService.SetMetricValues(request).Error; err != nil {
+			log.Printf("failed to set batch of %d metrics: %v", len(request.Metrics), err)
+			return toStatus(err)
+		}
+		updated += int64(len(request.Metrics))
+	}
+}
+
+func (h *handler) GetMetric(_ context.Context, req *pb.GetMetricRequest) (*pb.GetMetricResponse, error) {
+	metricType, err := fromType(req.GetType())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, domain.ErrItemNotFound.Error()) //nolint:wrapcheck // grpc status
+	}
+	metric, err := h.getStoredMetric(metricType, req.GetId(), req.GetLabels())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.GetMetricResponse{Metric: metric}, nil
+}
+
+func (h *handler) ListMetrics(_ context.Context, req *pb.ListMetricsRequest) (*pb.ListMetricsResponse, error) {
+	matchers, err := fromMatchers(req.GetMatchers())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error()) //nolint:wrapcheck // grpc status
+	}
+	response := &pb.ListMetricsResponse{}
+	for _, metricType := range metricTypes {
+		all := h.metricService.GetAllMetrics(&domain.GetAllMetricsRequest{
+			MetricType: metricType,
+			Matchers:   matchers,
+		})
+		if all.Error != nil {
+			log.Printf("failed to get metrics for metricType %s: %v", metricType, all.Error)
+			return nil, toStatus(all.Error)
+		}
+		for _, series := range all.Series {
+			metric, err := toMetric(metricType, series.Name, series.Labels, &domain.MetricResponse{
+				MetricValue: series.Value,
+				Histogram:   series.Histogram,
+				Summary:     series.Summary,
+			})
+			if err != nil {
+				return nil, toStatus(err)
+			}
+			response.Metrics = append(response.Metrics, metric)
+		}
+	}
+	return response, nil
+}
+
+func (h *handler) getStoredMetric(metricType, metricName string, labels domain.Labels) (*pb.Metric, error) {
+	response := h.metricService.GetMetricValue(&domain.MetricRequest{
+		MetricType: metricType,
+		MetricName: metricName,
+		Labels:     labels,
+	})
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	if !response.Found {
+		return nil, domain.ErrItemNotFound
+	}
+	return toMetric(metricType, metricName, labels, response)
+}
+
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrIncorrectMetricType), errors.Is(err, domain.ErrIncorrectMetricValue):
+		return status.Error(codes.InvalidArgument, err.Error()) //nolint:wrapcheck // grpc status
+	case errors.Is(err, domain.ErrItemNotFound):
+		return status.Error(codes.NotFound, err.Error()) //nolint:wrapcheck // grpc status
+	default:
+		return status.Error(codes.Internal, "internal error") //nolint:wrapcheck // grpc status
+	}
+}
+
+func fromType(metricType pb.Metric_Type) (string, error) {
+	switch metricType {
+	case pb.Metric_GAUGE:
+		return domain.Gauge, nil
+	case pb.Metric_COUNTER:
+		return domain.Counter, nil
+	case pb.Metric_HISTOGRAM:
+		return domain.Histogram, nil
+	case pb.Metric_SUMMARY:
+		return domain.Summary, nil
+	case pb.Metric_SET:
+		return domain.Set, nil
+	case pb.Metric_TYPE_UNSPECIFIED:
+		return "", domain.ErrIncorrectMetricType
+	default:
+		return "", domain.ErrIncorrectMetricType
+	}
+}
+
+func toSetMetricRequest(metric *pb.Metric) (*domain.SetMetricRequest, error) {
+	metricType, err := fromType(metric.GetType())
+	if err != nil {
+		return nil, err
+	}
+	request := &domain.SetMetricRequest{
+		MetricType: metricType,
+		MetricName: metric.GetId(),
+		Labels:     metric.GetLabels(),
+	}
+	switch value := metric.GetValue().(type) {
+	case *pb.Metric_Gauge:
+		if metricType != domain.Gauge {
+			return nil, domain.ErrIncorrectMetricValue
+		}
+		request.MetricValue = strconv.FormatFloat(value.Gauge, 'f', -1, 64)
+	case *pb.Metric_Delta:
+		if metricType != domain.Counter {
+			return nil, domain.ErrIncorrectMetricValue
+		}
+		request.MetricValue = strconv.FormatInt(value.Delta, 10)
+	case *pb.Metric_Observation:
+		if metricType != domain.Histogram && metricType != domain.Summary {
+			return nil, domain.ErrIncorrectMetricValue
+		}
+		request.MetricValue = strconv.FormatFloat(value.Observation, 'f', -1, 64)
+	case *pb.Metric_Member:
+		if metricType != domain.Set {
+			return nil, domain.ErrIncorrectMetricValue
+		}
+		request.MetricValue = value.Member
+	case *pb.Metric_Sketch:
+		if metricType != domain.Set {
+			return nil, domain.ErrIncorrectMetricValue
+		}
+		request.Sketch = value.Sketch
+	default:
+		return nil, domain.ErrIncorrectMetricValue
+	}
+	return request, nil
+}
+
+func toMetric(
+	metricType, metricName string,
+	labels domain.Labels,
+	response *domain.MetricResponse,
+) (*pb.Metric, error) {
+	metric := &pb.Metric{
+		Id:     metricName,
+		Labels: labels,
+	}
+	metricValue := response.MetricValue
+	switch metricType {
+	case domain.Gauge:
+		value, err := strconv.ParseFloat(metricValue, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", domain.ErrIncorrectMetricValue, err)
+		}
+		metric.Type = pb.Metric_GAUGE
+		metric.Value = &pb.Metric_Gauge{Gauge: value}
+	case domain.Counter:
+		delta, err := strconv.ParseInt(metricValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", domain.ErrIncorrectMetricValue, err)
+		}
+		metric.Type = pb.Metric_COUNTER
+		metric.Value = &pb.Metric_Delta{Delta: delta}
+	case domain.Histogram:
+		if response.Histogram == nil {
+			return nil, domain.ErrIncorrectMetricValue
+		}
+		metric.Type = pb.Metric_HISTOGRAM
+		metric.Value = &pb.Metric_Histogram{Histogram: toHistogram(response.Histogram)}
+	case domain.Summary:
+		if response.Summary == nil {
+			return nil, domain.ErrIncorrectMetricValue
+		}
+		metric.Type = pb.Metric_SUMMARY
+		metric.Value = &pb.Metric_Summary{Summary: toSummary(response.Summary)}
+	case domain.Set:
+		cardinality, err := strconv.ParseUint(metricValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", domain.ErrIncorrectMetricValue, err)
+		}
+		metric.Type = pb.Metric_SET
+		metric.Value = &pb.Metric_Cardinality{Cardinality: cardinality}
+	default:
+		return nil, domain.ErrIncorrectMetricType
+	}
+	return metric, nil
+}
+
+func toHistogram(histogram *domain.HistogramValue) *pb.HistogramValue {
+	result := &pb.HistogramValue{
+		Buckets: make([]*pb.HistogramValue_Bucket, 0, len(histogram.Buckets)),
+		Sum:     histogram.Sum,
+		Count:   histogram.Count,
+	}
+	for _, bucket := range histogram.Buckets {
+		result.Buckets = append(result.Buckets, &pb.HistogramValue_Bucket{
+			UpperBound: bucket.UpperBound,
+			Count:      bucket.Count,
+		})
+	}
+	return result
+}
+
+func toSummary(summary *domain.SummaryValue) *pb.SummaryValue {
+	result := &pb.SummaryValue{
+		Quantiles: make([]*pb.SummaryValue_Quantile, 0, len(summary.Quantiles)),
+		Sum:       summary.Sum,
+		Count:     summary.Count,
+	}
+	for _, quantile := range summary.Quantiles {
+		result.Quantiles = append(result.Quantiles, &pb.SummaryValue_Quantile{
+			Quantile: quantile.Quantile,
+			Value:    quantile.Value,
+		})
+	}
+	return result
+}
+
+func fromMatchers(matchers []*pb.LabelMatcher) ([]*domain.LabelMatcher, error) {
+	result := make([]*domain.LabelMatcher, 0, len(matchers))
+	for _, matcher := range matchers {
+		var matchType string
+		switch matcher.GetType() {
+		case pb.LabelMatcher_EQUAL:
+			matchType = domain.MatchEqual
+		case pb.LabelMatcher_NOT_EQUAL:
+			matchType = domain.MatchNotEqual
+		case pb.LabelMatcher_REGEXP:
+			matchType = domain.MatchRegexp
+		case pb.LabelMatcher_NOT_REGEXP:
+			matchType = domain.MatchNotRegexp
+		}
+		labelMatcher, err := domain.NewLabelMatcher(matchType, matcher.GetName(), matcher.GetValue())
+		if err != nil {
+			return nil, err //nolint:wrapcheck // already a matcher error
+		}
+		result = append(result, labelMatcher)
+	}
+	return result, nil
+}
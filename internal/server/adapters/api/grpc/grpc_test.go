@@ -0,0 +1,318 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/agatma/sprint1-http-server/internal/proto"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+const bufSize = 1024 * 1024
+
+func newTestClient(t *testing.T, cfg *Config) pb.MetricsClient {
+	t.Helper()
+	newStorage := func() storage.MetricStorage {
+		metricStorage, err := storage.NewStorage(storage.Config{
+			Memory: &memory.Config{},
+		})
+		require.NoError(t, err)
+		return metricStorage
+	}
+	metricService := service.NewMetricService(
+		newStorage(),
+		newStorage(),
+		service.WithHistogramStorage(newStorage()),
+		service.WithSummaryStorage(newStorage()),
+		service.WithSetStorage(newStorage()),
+	)
+	api, err := NewAPI(metricService, cfg)
+	require.NoError(t, err)
+	listener := bufconn.Listen(bufSize)
+	go func() {
+		_ = api.srv.Serve(listener)
+	}()
+	t.Cleanup(api.srv.Stop)
+	conn, err := gogrpc.NewClient(
+		"passthrough:///bufnet",
+		gogrpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		gogrpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return pb.NewMetricsClient(conn)
+}
+
+func gauge(id string, value float64) *pb.Metric {
+	return &pb.Metric{Id: id, Type: pb.Metric_GAUGE, Value: &pb.Metric_Gauge{Gauge: value}}
+}
+
+func counter(id string, delta int64) *pb.Metric {
+	return &pb.Metric{Id: id, Type: pb.Metric_COUNTER, Value: &pb.Metric_Delta{Delta: delta}}
+}
+
+func TestAPI_UpdateMetric(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric *pb.Metric
+		code   codes.Code
+	}{
+		{
+			name:   "gauge",
+			metric: gauge("someMetric", 13.5),
+			code:   codes.OK,
+		},
+		{
+			name:   "counter",
+			metric: counter("someMetric", 13),
+			code:   codes.OK,
+		},
+		{
+			name:   "unspecifiedType",
+			metric: &pb.Metric{Id: "someMetric", Value: &pb.Metric_Gauge{Gauge: 1}},
+			code:   codes.InvalidArgument,
+		},
+		{
+			name:   "mismatchedValue",
+			metric: &pb.Metric{Id: "someMetric", Type: pb.Metric_COUNTER, Value: &pb.Metric_Gauge{Gauge: 1}},
+			code:   codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, &Config{})
+			resp, err := client.UpdateMetric(context.Background(), &pb.UpdateMetricRequest{Metric: tt.metric})
+			assert.Equal(t, tt.code, status.Code(err))
+			if tt.code == codes.OK {
+				assert.Equal(t, tt.metric.GetValue(), resp.GetMetric().GetValue())
+			}
+		})
+	}
+}
+
+func TestAPI_UpdateMetrics(t *testing.T) {
+	client := newTestClient(t, &Config{})
+	ctx := context.Background()
+
+	stream, err := client.UpdateMetrics(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.UpdateMetricsRequest{
+		Metrics: []*pb.Metric{gauge("g", 1.5), counter("c", 2)},
+	}))
+	require.NoError(t, stream.Send(&pb.UpdateMetricsRequest{
+		Metrics: []*pb.Metric{counter("c", 3)},
+	}))
+	resp, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), resp.GetUpdated())
+
+	stream, err = client.UpdateMetrics(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.UpdateMetricsRequest{
+		Metrics: []*pb.Metric{counter("c", 10), {Id: "broken", Type: pb.Metric_GAUGE}},
+	}))
+	_, err = stream.CloseAndRecv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	got, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "c", Type: pb.Metric_COUNTER})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), got.GetMetric().GetDelta())
+
+	list, err := client.ListMetrics(ctx, &pb.ListMetricsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.GetMetrics(), 2)
+}
+
+func TestAPI_MetricTypes(t *testing.T) {
+	client := newTestClient(t, &Config{})
+	ctx := context.Background()
+
+	updates := []*pb.Metric{
+		gauge("g", 1.5),
+		counter("c", 2),
+		{Id: "h", Type: pb.Metric_HISTOGRAM, Value: &pb.Metric_Observation{Observation: 0.3}},
+		{Id: "s", Type: pb.Metric_SUMMARY, Value: &pb.Metric_Observation{Observation: 4}},
+		{Id: "u", Type: pb.Metric_SET, Value: &pb.Metric_Member{Member: "alice"}},
+		{Id: "u", Type: pb.Metric_SET, Value: &pb.Metric_Member{Member: "bob"}},
+	}
+	for _, metric := range updates {
+		_, err := client.UpdateMetric(ctx, &pb.UpdateMetricRequest{Metric: metric})
+		require.NoError(t, err)
+	}
+	_, err := client.UpdateMetric(ctx, &pb.UpdateMetricRequest{
+		Metric: &pb.Metric{Id: "h", Type: pb.Metric_HISTOGRAM, Value: &pb.Metric_Delta{Delta: 1}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	list, err := client.ListMetrics(ctx, &pb.ListMetricsRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.GetMetrics(), 5)
+	histogram := list.GetMetrics()[2].GetHistogram()
+	require.NotNil(t, histogram)
+	assert.Equal(t, uint64(1), histogram.GetCount())
+	assert.InDelta(t, 0.3, histogram.GetSum(), 1e-9)
+	summary := list.GetMetrics()[3].GetSummary()
+	require.NotNil(t, summary)
+	assert.Equal(t, uint64(1), summary.GetCount())
+	assert.NotEmpty(t, summary.GetQuantiles())
+	assert.Equal(t, uint64(2), list.GetMetrics()[4].GetCardinality())
+
+	got, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "u", Type: pb.Metric_SET})
+	require.NoError(t, err)
+	assert.Equal(t, pb.Metric_SET, got.GetMetric().GetType())
+	assert.Equal(t, uint64(2), got.GetMetric().GetCardinality())
+}
+
+func TestAPI_Labels(t *testing.T) {
+	client := newTestClient(t, &Config{})
+	ctx := context.Background()
+
+	for host, value := range map[string]float64{"web01": 0.5, "db01": 0.75} {
+		metric := gauge("cpu", value)
+		metric.Labels = map[string]string{"host": host}
+		_, err := client.UpdateMetric(ctx, &pb.UpdateMetricRequest{Metric: metric})
+		require.NoError(t, err)
+	}
+
+	got, err := client.GetMetric(ctx, &pb.GetMetricRequest{
+		Id:     "cpu",
+		Type:   pb.Metric_GAUGE,
+		Labels: map[string]string{"host": "db01"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0.75, got.GetMetric().GetGauge())
+	_, err = client.GetMetric(ctx, &pb.GetMetricRequest{Id: "cpu", Type: pb.Metric_GAUGE})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	list, err := client.ListMetrics(ctx, &pb.ListMetricsRequest{
+		Matchers: []*pb.LabelMatcher{{Type: pb.LabelMatcher_REGEXP, Name: "host", Value: "web.*"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, list.GetMetrics(), 1)
+	assert.Equal(t, map[string]string{"host": "web01"}, list.GetMetrics()[0].GetLabels())
+
+	_, err = client.ListMetrics(ctx, &pb.ListMetricsRequest{
+		Matchers: []*pb.LabelMatcher{{Type: pb.LabelMatcher_REGEXP, Name: "host", Value: "("}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestAPI_GetMetricNotFound(t *testing.T) {
+	client := newTestClient(t, &Config{})
+	_, err := client.GetMetric(context.Background(), &pb.GetMetricRequest{Id: "unknown", Type: pb.Metric_GAUGE})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestAPI_TrustedSubnet(t *testing.T) {
+	tests := []struct {
+		name   string
+		realIP string
+		code   codes.Code
+	}{
+		{
+			name:   "trustedAddress",
+			realIP: "192.168.1.15",
+			code:   codes.OK,
+		},
+		{
+			name:   "untrustedAddress",
+			realIP: "10.0.0.1",
+			code:   codes.PermissionDenied,
+		},
+		{
+			name: "missingAddress",
+			code: codes.PermissionDenied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, &Config{TrustedSubnet: "192.168.1.0/24"})
+			ctx := context.Background()
+			if tt.realIP != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, realIPKey, tt.realIP)
+			}
+			_, err := client.UpdateMetric(ctx, &pb.UpdateMetricRequest{Metric: gauge("someMetric", 1)})
+			assert.Equal(t, tt.code, status.Code(err))
+			_, err = client.ListMetrics(context.Background(), &pb.ListMetricsRequest{})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAPI_Signature(t *testing.T) {
+	const key = "secret"
+	sum := func(t *testing.T, msg proto.Message, key string) string {
+		t.Helper()
+		hash, err := sign(msg, key)
+		require.NoError(t, err)
+		return hash
+	}
+	update := &pb.UpdateMetricRequest{Metric: gauge("someMetric", 1)}
+	first := &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{counter("c", 1)}}
+	second := &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{counter("c", 2)}}
+	tests := []struct {
+		name   string
+		hashes []string
+		code   codes.Code
+	}{
+		{
+			name:   "signed",
+			hashes: []string{sum(t, first, key), sum(t, second, key)},
+			code:   codes.OK,
+		},
+		{
+			name: "unsigned",
+			code: codes.Unauthenticated,
+		},
+		{
+			name:   "wrongKey",
+			hashes: []string{sum(t, first, "other"), sum(t, second, "other")},
+			code:   codes.Unauthenticated,
+		},
+		{
+			name:   "unsignedSecondBatch",
+			hashes: []string{sum(t, first, key)},
+			code:   codes.Unauthenticated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, &Config{Key: key})
+			ctx := context.Background()
+			for _, hash := range tt.hashes {
+				ctx = metadata.AppendToOutgoingContext(ctx, hashKey, hash)
+			}
+			stream, err := client.UpdateMetrics(ctx)
+			require.NoError(t, err)
+			_ = stream.Send(first)
+			_ = stream.Send(second)
+			_, err = stream.CloseAndRecv()
+			assert.Equal(t, tt.code, status.Code(err))
+
+			_, err = client.UpdateMetric(context.Background(), update)
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+			signed := metadata.AppendToOutgoingContext(context.Background(), hashKey, sum(t, update, key))
+			_, err = client.UpdateMetric(signed, update)
+			assert.NoError(t, err)
+			_, err = client.ListMetrics(context.Background(), &pb.ListMetricsRequest{})
+			assert.NoError(t, err)
+		})
+	}
+}
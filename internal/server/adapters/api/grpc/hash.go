@@ -0,0 +1,102 @@
+package grpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const hashKey = "hashsha256"
+
+var errSignatureMismatch = status.Error(codes.Unauthenticated, "signature mismatch")
+
+// sign returns the hex encoded HMAC-SHA256 of the deterministic encoding of
+// msg. Clients put it into the hashsha256 metadata of ingest calls, one value
+// per message of a stream in the order they are sent.
+func sign(msg proto.Message, key string) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal message: %w", err)
+	}
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verify checks msg against the i-th hashsha256 value of the call metadata.
+func verify(ctx context.Context, key, method string, msg any, i int) error {
+	var hashes []string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		hashes = md.Get(hashKey)
+	}
+	message, ok := msg.(proto.Message)
+	if !ok || i >= len(hashes) {
+		log.Printf("missing signature for %s", method)
+		return errSignatureMismatch
+	}
+	hash, err := sign(message, key)
+	if err != nil || !hmac.Equal([]byte(hashes[i]), []byte(hash)) {
+		log.Printf("signature mismatch for %s", method)
+		return errSignatureMismatch
+	}
+	return nil
+}
+
+func hashUnaryInterceptor(key string) gogrpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *gogrpc.UnaryServerInfo,
+		handler gogrpc.UnaryHandler,
+	) (any, error) {
+		if key != "" && ingestMethods[info.FullMethod] {
+			if err := verify(ctx, key, info.FullMethod, req, 0); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// hashServerStream verifies every received message before the handler sees
+// it.
+type hashServerStream struct {
+	gogrpc.ServerStream
+	key      string
+	method   string
+	received int
+}
+
+func (s *hashServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err //nolint:wrapcheck // io.EOF must reach the handler as is
+	}
+	if err := verify(s.Context(), s.key, s.method, m, s.received); err != nil {
+		return err
+	}
+	s.received++
+	return nil
+}
+
+func hashStreamInterceptor(key string) gogrpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream gogrpc.ServerStream,
+		info *gogrpc.StreamServerInfo,
+		handler gogrpc.StreamHandler,
+	) error {
+		if key != "" && ingestMethods[info.FullMethod] {
+			stream = &hashServerStream{ServerStream: stream, key: key, method: info.FullMethod}
+		}
+		return handler(srv, stream)
+	}
+}
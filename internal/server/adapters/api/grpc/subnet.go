@@ -0,0 +1,68 @@
+package grpc
+
+import (
+	"context"
+	"log"
+	"net"
+
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/agatma/sprint1-http-server/internal/proto"
+)
+
+const realIPKey = "x-real-ip"
+
+var ingestMethods = map[string]bool{
+	pb.Metrics_UpdateMetric_FullMethodName:  true,
+	pb.Metrics_UpdateMetrics_FullMethodName: true,
+}
+
+// trusted mirrors the REST subnet check: ingest calls must carry an
+// x-real-ip metadata value inside the trusted subnet.
+func trusted(ctx context.Context, subnet *net.IPNet, method string) error {
+	if subnet == nil || !ingestMethods[method] {
+		return nil
+	}
+	var ip net.IP
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(realIPKey); len(values) > 0 {
+			ip = net.ParseIP(values[0])
+		}
+	}
+	if ip == nil || !subnet.Contains(ip) {
+		log.Printf("rejected %s from untrusted address %v", method, ip)
+		return status.Error(codes.PermissionDenied, "untrusted address") //nolint:wrapcheck // grpc status
+	}
+	return nil
+}
+
+func subnetUnaryInterceptor(subnet *net.IPNet) gogrpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *gogrpc.UnaryServerInfo,
+		handler gogrpc.UnaryHandler,
+	) (any, error) {
+		if err := trusted(ctx, subnet, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func subnetStreamInterceptor(subnet *net.IPNet) gogrpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream gogrpc.ServerStream,
+		info *gogrpc.StreamServerInfo,
+		handler gogrpc.StreamHandler,
+	) error {
+		if err := trusted(stream.Context(), subnet, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
@@ -0,0 +1,45 @@
+package rest
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+type AlertService interface {
+	GetAlerts(request *domain.GetAlertsRequest) *domain.GetAlertsResponse
+}
+
+// Option adds optional services to the API.
+type Option func(*handler)
+
+// WithAlertService exposes the alerts of alertService on /alerts.
+func WithAlertService(alertService AlertService) Option {
+	return func(h *handler) {
+		h.alertService = alertService
+	}
+}
+
+type alertsResponse struct {
+	Alerts []*domain.Alert `json:"alerts"`
+}
+
+// GetAlerts lists the current alerts. The state query parameter keeps only
+// the inactive, pending or firing ones.
+func (h *handler) GetAlerts(w http.ResponseWriter, req *http.Request) {
+	state := req.URL.Query().Get("state")
+	switch state {
+	case "", domain.AlertInactive, domain.AlertPending, domain.AlertFiring:
+	default:
+		http.Error(w, "unknown alert state", http.StatusBadRequest)
+		return
+	}
+	response := h.alertService.GetAlerts(&domain.GetAlertsRequest{State: state})
+	if response.Error != nil {
+		log.Printf("failed to get alerts: %v", response.Error)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, &alertsResponse{Alerts: response.Alerts})
+}
@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func TestHandler_GetAlerts(t *testing.T) {
+	metricService := newTestMetricService(t)
+	alertService := service.NewAlertService(metricService, []*domain.AlertRule{
+		{Name: "Gauge", MetricType: domain.Gauge, Metric: "gaugeMetric", Op: domain.OpGreater, Threshold: 1},
+		{Name: "Counter", MetricType: domain.Counter, Metric: "counterMetric", Op: domain.OpGreater, Threshold: 10},
+	})
+	require.NoError(t, alertService.Evaluate(time.Date(2024, 4, 5, 10, 0, 0, 0, time.UTC)))
+	api, err := NewAPI(metricService, &Config{}, WithAlertService(alertService))
+	require.NoError(t, err)
+	tests := []struct {
+		name       string
+		url        string
+		statusCode int
+		want       string
+	}{
+		{
+			name:       "all",
+			url:        "/alerts",
+			statusCode: http.StatusOK,
+			want: `{"alerts":[` +
+				`{"rule":"Counter","metric":"counterMetric","state":"inactive","value":5,"threshold":10},` +
+				`{"rule":"Gauge","metric":"gaugeMetric","state":"firing","value":1.25,"threshold":1,` +
+				`"active_at":"2024-04-05T10:00:00Z"}]}`,
+		},
+		{
+			name:       "firing",
+			url:        "/alerts?state=firing",
+			statusCode: http.StatusOK,
+			want: `{"alerts":[{"rule":"Gauge","metric":"gaugeMetric","state":"firing","value":1.25,"threshold":1,` +
+				`"active_at":"2024-04-05T10:00:00Z"}]}`,
+		},
+		{
+			name:       "pending",
+			url:        "/alerts?state=pending",
+			statusCode: http.StatusOK,
+			want:       `{"alerts":[]}`,
+		},
+		{
+			name:       "badState",
+			url:        "/alerts?state=resolved",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			api.srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, http.NoBody))
+			assert.Equal(t, tt.statusCode, w.Code)
+			if tt.want != "" {
+				assert.JSONEq(t, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -3,18 +3,115 @@ package rest
 import (
 	"flag"
 	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
 
 	"github.com/caarlos0/env/v11"
 )
 
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+
+	defaultStatsDFlushInterval = 10
+	defaultGraphiteIdleTimeout = 30
+	defaultGraphiteMaxLines    = 10000
+	defaultHistoryRetention    = 3600
+	defaultHistoryMaxSamples   = 1000
+	defaultRollupInterval      = 60
+	defaultStaleAfter          = 300
+	defaultJanitorInterval     = 60
+	defaultAlertInterval       = 15
+	defaultWebhookRepeat       = 4 * 60 * 60
+	defaultWebhookRetries      = 5
+)
+
 type Config struct {
-	Address string `env:"ADDRESS"`
+	Address       string `env:"ADDRESS"`
+	LogLevel      string `env:"LOG_LEVEL"`
+	LogFormat     string `env:"LOG_FORMAT"`
+	Key           string `env:"KEY"`
+	CryptoKey     string `env:"CRYPTO_KEY"`
+	TrustedSubnet string `env:"TRUSTED_SUBNET"`
+	GRPCAddress   string `env:"GRPC_ADDRESS"`
+	StatsDAddress string `env:"STATSD_ADDRESS"`
+	StatsDFlush   int    `env:"STATSD_FLUSH_INTERVAL"`
+	GraphiteAddr  string `env:"GRAPHITE_ADDRESS"`
+	GraphiteIdle  int    `env:"GRAPHITE_IDLE_TIMEOUT"`
+	GraphiteLines int    `env:"GRAPHITE_MAX_LINES"`
+	HistorySize   int    `env:"HISTORY_MAX_SAMPLES"`
+	MetricTTL     int    `env:"METRIC_TTL"`
+	JanitorEvery  int    `env:"JANITOR_INTERVAL"`
+	AlertRules    string `env:"ALERT_RULES"`
+	AlertEvery    int    `env:"ALERT_INTERVAL"`
+	// WebhookURLs receive alert notifications, none are sent when it's empty.
+	WebhookURLs []string `env:"WEBHOOK_URLS" envSeparator:","`
+	// Buckets are the histogram upper bounds, the storage defaults when empty.
+	Buckets []float64 `env:"HISTOGRAM_BUCKETS" envSeparator:","`
+	// HistoryAge, RollupEvery, StaleAfter, WebhookRepeat and WebhookTries are
+	// pointers because 0 disables them, so an unset variable has to be told
+	// apart from a zero one.
+	HistoryAge    *int `env:"HISTORY_RETENTION"`
+	RollupEvery   *int `env:"ROLLUP_INTERVAL"`
+	StaleAfter    *int `env:"METRIC_STALE_AFTER"`
+	WebhookRepeat *int `env:"WEBHOOK_REPEAT_INTERVAL"`
+	WebhookTries  *int `env:"WEBHOOK_RETRIES"`
 }
 
 func NewConfig() (*Config, error) {
-	var flagRunAddr *string
-	var cfg Config
+	var (
+		cfg           Config
+		flagRunAddr   *string
+		flagLogLevel  *string
+		flagLogFormat *string
+		flagKey       *string
+		flagCryptoKey *string
+		flagSubnet    *string
+		flagGRPCAddr  *string
+		flagStatsD    *string
+		flagStatsDInt *int
+		flagGraphite  *string
+		flagGraphIdle *int
+		flagGraphMax  *int
+		flagHistAge   *int
+		flagHistSize  *int
+		flagRollup    *int
+		flagStale     *int
+		flagTTL       *int
+		flagJanitor   *int
+		flagBuckets   *string
+		flagRules     *string
+		flagAlert     *int
+		flagWebhooks  *string
+		flagRepeat    *int
+		flagRetries   *int
+	)
 	flagRunAddr = flag.String("a", ":8080", "address and port to run server")
+	flagLogLevel = flag.String("l", "info", "log level: debug, info, warn or error")
+	flagLogFormat = flag.String("f", logFormatText, "log format: text or json")
+	flagKey = flag.String("k", "", "key to check and sign payloads with HMAC-SHA256")
+	flagCryptoKey = flag.String("crypto-key", "", "path to the private key to decrypt request bodies")
+	flagSubnet = flag.String("t", "", "trusted subnet in CIDR notation for ingest requests")
+	flagGRPCAddr = flag.String("g", "", "address and port to run grpc server, disabled when empty")
+	flagStatsD = flag.String("statsd", "", "udp address to accept statsd packets on, disabled when empty")
+	flagStatsDInt = flag.Int("statsd-flush", defaultStatsDFlushInterval, "statsd flush interval in seconds")
+	flagGraphite = flag.String("graphite", "", "tcp address to accept graphite plaintext on, disabled when empty")
+	flagGraphIdle = flag.Int("graphite-idle", defaultGraphiteIdleTimeout, "graphite idle connection timeout in seconds")
+	flagGraphMax = flag.Int("graphite-max-lines", defaultGraphiteMaxLines, "lines accepted per graphite connection")
+	flagHistAge = flag.Int("history-retention", defaultHistoryRetention, "seconds of history per series, 0 disables it")
+	flagHistSize = flag.Int("history-max-samples", defaultHistoryMaxSamples, "samples of history kept per series")
+	flagRollup = flag.Int("rollup-interval", defaultRollupInterval, "seconds between history rollups, 0 disables them")
+	flagStale = flag.Int("stale-after", defaultStaleAfter, "seconds without updates till a metric is stale, 0 disables it")
+	flagTTL = flag.Int("metric-ttl", 0, "seconds without updates after which a metric expires, 0 keeps metrics forever")
+	flagJanitor = flag.Int("janitor-interval", defaultJanitorInterval, "seconds between evictions of expired metrics")
+	flagBuckets = flag.String("histogram-buckets", "", "comma separated histogram upper bounds, defaults when empty")
+	flagRules = flag.String("alert-rules", "", "path to a JSON file of alert rules, alerting is disabled when empty")
+	flagAlert = flag.Int("alert-interval", defaultAlertInterval, "seconds between evaluations of the alert rules")
+	flagWebhooks = flag.String("webhook-urls", "", "comma separated urls to post alert notifications to")
+	flagRepeat = flag.Int("webhook-repeat", defaultWebhookRepeat, "seconds between alert repeats, 0 never repeats")
+	flagRetries = flag.Int("webhook-retries", defaultWebhookRetries, "webhook delivery retries, 0 disables them")
 	err := env.Parse(&cfg)
 	if err != nil {
 		return &cfg, fmt.Errorf("failed to get config for server: %w", err)
@@ -23,5 +120,122 @@ func NewConfig() (*Config, error) {
 	if cfg.Address == "" {
 		cfg.Address = *flagRunAddr
 	}
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = *flagLogLevel
+	}
+	if cfg.LogFormat == "" {
+		cfg.LogFormat = *flagLogFormat
+	}
+	if cfg.Key == "" {
+		cfg.Key = *flagKey
+	}
+	if cfg.CryptoKey == "" {
+		cfg.CryptoKey = *flagCryptoKey
+	}
+	if cfg.TrustedSubnet == "" {
+		cfg.TrustedSubnet = *flagSubnet
+	}
+	if cfg.GRPCAddress == "" {
+		cfg.GRPCAddress = *flagGRPCAddr
+	}
+	if cfg.StatsDAddress == "" {
+		cfg.StatsDAddress = *flagStatsD
+	}
+	if cfg.StatsDFlush == 0 {
+		cfg.StatsDFlush = *flagStatsDInt
+	}
+	if cfg.GraphiteAddr == "" {
+		cfg.GraphiteAddr = *flagGraphite
+	}
+	if cfg.GraphiteIdle == 0 {
+		cfg.GraphiteIdle = *flagGraphIdle
+	}
+	if cfg.GraphiteLines == 0 {
+		cfg.GraphiteLines = *flagGraphMax
+	}
+	if cfg.HistoryAge == nil {
+		cfg.HistoryAge = flagHistAge
+	}
+	if cfg.HistorySize == 0 {
+		cfg.HistorySize = *flagHistSize
+	}
+	if cfg.RollupEvery == nil {
+		cfg.RollupEvery = flagRollup
+	}
+	if cfg.StaleAfter == nil {
+		cfg.StaleAfter = flagStale
+	}
+	if cfg.MetricTTL == 0 {
+		cfg.MetricTTL = *flagTTL
+	}
+	if cfg.JanitorEvery == 0 {
+		cfg.JanitorEvery = *flagJanitor
+	}
+	if cfg.AlertRules == "" {
+		cfg.AlertRules = *flagRules
+	}
+	if cfg.AlertEvery == 0 {
+		cfg.AlertEvery = *flagAlert
+	}
+	if len(cfg.WebhookURLs) == 0 && *flagWebhooks != "" {
+		cfg.WebhookURLs = strings.Split(*flagWebhooks, ",")
+	}
+	if cfg.WebhookRepeat == nil {
+		cfg.WebhookRepeat = flagRepeat
+	}
+	if cfg.WebhookTries == nil {
+		cfg.WebhookTries = flagRetries
+	}
+	if len(cfg.Buckets) == 0 && *flagBuckets != "" {
+		if cfg.Buckets, err = parseBuckets(*flagBuckets); err != nil {
+			return &cfg, fmt.Errorf("invalid histogram buckets: %w", err)
+		}
+	}
+	if err = validateBuckets(cfg.Buckets); err != nil {
+		return &cfg, fmt.Errorf("invalid histogram buckets: %w", err)
+	}
+	if err = validateLogConfig(&cfg); err != nil {
+		return &cfg, fmt.Errorf("invalid log config: %w", err)
+	}
 	return &cfg, nil
 }
+
+func validateLogConfig(cfg *Config) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		return fmt.Errorf("unknown log level %q: %w", cfg.LogLevel, err)
+	}
+	switch cfg.LogFormat {
+	case logFormatText, logFormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.LogFormat)
+	}
+}
+
+func parseBuckets(value string) ([]float64, error) {
+	fields := strings.Split(value, ",")
+	buckets := make([]float64, 0, len(fields))
+	for _, field := range fields {
+		bucket, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad bucket %q: %w", field, err)
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, nil
+}
+
+// validateBuckets requires finite upper bounds in strictly ascending order.
+// The +Inf bucket is always added.
+func validateBuckets(buckets []float64) error {
+	for i, bucket := range buckets {
+		if math.IsNaN(bucket) || math.IsInf(bucket, 0) {
+			return fmt.Errorf("bucket %v is not finite", bucket)
+		}
+		if i > 0 && bucket <= buckets[i-1] {
+			return fmt.Errorf("bucket %v is not greater than %v", bucket, buckets[i-1])
+		}
+	}
+	return nil
+}
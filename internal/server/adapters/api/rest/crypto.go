@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+)
+
+var errCiphertextTooShort = errors.New("ciphertext too short")
+
+// loadPrivateKey reads a PEM encoded RSA private key in either PKCS#1 or
+// PKCS#8 form, as produced by openssl.
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found in private key file")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// decrypt reverses the agent's hybrid encryption: the body starts with an
+// RSA-OAEP wrapped AES-256 key, followed by the GCM nonce and ciphertext.
+func decrypt(data []byte, privateKey *rsa.PrivateKey) ([]byte, error) {
+	keySize := privateKey.Size()
+	if len(data) < keySize {
+		return nil, errCiphertextTooShort
+	}
+	sessionKey, err := rsa.DecryptOAEP(sha256.New(), nil, privateKey, data[:keySize], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt session key: %w", err)
+	}
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcm: %w", err)
+	}
+	data = data[keySize:]
+	if len(data) < gcm.NonceSize() {
+		return nil, errCiphertextTooShort
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt body: %w", err)
+	}
+	return plaintext, nil
+}
+
+// decryptMiddleware decrypts request bodies before any other decoding. Once a
+// private key is configured, every non-empty body on the agent routes has to
+// be encrypted.
+func decryptMiddleware(privateKey *rsa.PrivateKey) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if privateKey == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				log.Printf("failed to read request body: %v", err)
+				http.Error(w, "failed to read body", bodyErrorStatus(err))
+				return
+			}
+			if len(body) > 0 {
+				if body, err = decrypt(body, privateKey); err != nil {
+					log.Printf("failed to decrypt request body for %s %s: %v", req.Method, req.RequestURI, err)
+					http.Error(w, "failed to decrypt body", http.StatusBadRequest)
+					return
+				}
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+			next.ServeHTTP(w, req)
+		})
+	}
+}
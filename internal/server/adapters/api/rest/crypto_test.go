@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+const testRSAKeyBits = 2048
+
+func testEncrypt(t *testing.T, data []byte, publicKey *rsa.PublicKey) []byte {
+	t.Helper()
+	sessionKey := make([]byte, 32)
+	_, err := rand.Read(sessionKey)
+	require.NoError(t, err)
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, sessionKey, nil)
+	require.NoError(t, err)
+	block, err := aes.NewCipher(sessionKey)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	require.NoError(t, err)
+	out := append(wrappedKey, nonce...)
+	return gcm.Seal(out, nonce, data, nil)
+}
+
+func TestDecryptMiddleware(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, testRSAKeyBits)
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "private.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	})
+	require.NoError(t, os.WriteFile(keyPath, keyPEM, 0o600))
+
+	body := []byte(`{"id":"someMetric","type":"gauge","value":13.5}`)
+	otherKey, err := rsa.GenerateKey(rand.Reader, testRSAKeyBits)
+	require.NoError(t, err)
+	tests := []struct {
+		name       string
+		url        string
+		body       []byte
+		statusCode int
+		stored     string
+	}{
+		{
+			name:       "encryptedBody",
+			url:        "/update/",
+			body:       testEncrypt(t, body, &privateKey.PublicKey),
+			statusCode: http.StatusOK,
+			stored:     "13.5",
+		},
+		{
+			name:       "plainBody",
+			url:        "/update/",
+			body:       body,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "encryptedForAnotherKey",
+			url:        "/update/",
+			body:       testEncrypt(t, body, &otherKey.PublicKey),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "plainBodyOutsideAgentRoutes",
+			url:        "/metrics",
+			body:       []byte("# TYPE someMetric gauge\nsomeMetric 13.5\n"),
+			statusCode: http.StatusOK,
+			stored:     "13.5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gaugeStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			counterStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			metricService := service.NewMetricService(gaugeStorage, counterStorage)
+			api, err := NewAPI(metricService, &Config{CryptoKey: keyPath})
+			require.NoError(t, err)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, tt.url, bytes.NewReader(tt.body))
+			api.srv.Handler.ServeHTTP(w, r)
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			value := metricService.GetMetricValue(&domain.MetricRequest{
+				MetricType: domain.Gauge,
+				MetricName: "someMetric",
+			})
+			assert.Equal(t, tt.stored, value.MetricValue)
+		})
+	}
+}
+
+func TestLoadPrivateKeyMissingFile(t *testing.T) {
+	_, err := loadPrivateKey(filepath.Join(t.TempDir(), "missing.pem"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,255 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gzipMinLength is the smallest response body that gets compressed. Anything
+// shorter would usually grow once the gzip header and footer are added.
+const gzipMinLength = 512
+
+// maxBodySize caps request bodies as they arrive and again once they are
+// decoded, so neither a large upload nor a small gzip bomb can exhaust memory.
+const maxBodySize = 32 << 20
+
+var gzipContentTypes = map[string]bool{
+	"application/json": true,
+	"text/html":        true,
+	"text/plain":       true,
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+type gzipReader struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+}
+
+func newGzipReader(body io.ReadCloser) (*gzipReader, error) {
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	return &gzipReader{
+		body: body,
+		zr:   zr,
+	}, nil
+}
+
+func (r *gzipReader) Read(p []byte) (int, error) {
+	return r.zr.Read(p) //nolint:wrapcheck // callers compare against io.EOF
+}
+
+func (r *gzipReader) Close() error {
+	if err := r.body.Close(); err != nil {
+		return fmt.Errorf("failed to close request body: %w", err)
+	}
+	if err := r.zr.Close(); err != nil {
+		return fmt.Errorf("failed to close gzip reader: %w", err)
+	}
+	return nil
+}
+
+// gzipWriter buffers the beginning of a response until it knows whether the
+// body is worth compressing, then either switches to gzip or flushes it as is.
+type gzipWriter struct {
+	http.ResponseWriter
+	zw          *gzip.Writer
+	buf         bytes.Buffer
+	statusCode  int
+	wroteHeader bool
+	compress    bool
+	decided     bool
+}
+
+func newGzipWriter(w http.ResponseWriter) *gzipWriter {
+	return &gzipWriter{
+		ResponseWriter: w,
+		statusCode:     http.StatusOK,
+	}
+}
+
+func (w *gzipWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.statusCode = statusCode
+}
+
+func (w *gzipWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		if !w.compressible() {
+			if err := w.start(false); err != nil {
+				return 0, err
+			}
+		} else {
+			w.buf.Write(p)
+			if w.buf.Len() < gzipMinLength {
+				return len(p), nil
+			}
+			if err := w.start(true); err != nil {
+				return 0, err
+			}
+			return len(p), nil
+		}
+	}
+	if w.compress {
+		n, err := w.zw.Write(p)
+		if err != nil {
+			return n, fmt.Errorf("failed to write gzip response: %w", err)
+		}
+		return n, nil
+	}
+	n, err := w.ResponseWriter.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("failed to write response: %w", err)
+	}
+	return n, nil
+}
+
+func (w *gzipWriter) compressible() bool {
+	if w.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return gzipContentTypes[mediaType]
+}
+
+// start sends the buffered status line and body to the client, switching the
+// rest of the response to gzip when compress is set.
+func (w *gzipWriter) start(compress bool) error {
+	w.decided = true
+	w.compress = compress
+	if compress {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.Header().Add("Vary", "Accept-Encoding")
+		zw, ok := gzipWriterPool.Get().(*gzip.Writer)
+		if !ok {
+			zw = gzip.NewWriter(io.Discard)
+		}
+		zw.Reset(w.ResponseWriter)
+		w.zw = zw
+	}
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if compress {
+		_, err = w.zw.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	if err != nil {
+		return fmt.Errorf("failed to write buffered response: %w", err)
+	}
+	return nil
+}
+
+// Close flushes whatever is still buffered. Short bodies end up here without
+// ever being compressed.
+func (w *gzipWriter) Close() error {
+	if !w.decided {
+		if !w.wroteHeader {
+			return nil
+		}
+		if err := w.start(false); err != nil {
+			return err
+		}
+	}
+	if w.zw == nil {
+		return nil
+	}
+	defer gzipWriterPool.Put(w.zw)
+	if err := w.zw.Close(); err != nil {
+		return fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+	return nil
+}
+
+// acceptsGzip reports whether the client lists gzip in Accept-Encoding
+// without refusing it with q=0.
+func acceptsGzip(req *http.Request) bool {
+	for _, encoding := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(encoding, ";")
+		if strings.TrimSpace(params[0]) != "gzip" {
+			continue
+		}
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if q, err := strconv.ParseFloat(value, 64); name == "q" && err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// bodyErrorStatus tells an oversized body apart from one that couldn't be read.
+func bodyErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// limitBodyMiddleware caps every request body before any other middleware
+// reads it.
+func limitBodyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.Body = http.MaxBytesReader(w, req.Body, maxBodySize)
+		next.ServeHTTP(w, req)
+	})
+}
+
+func gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.Contains(req.Header.Get("Content-Encoding"), "gzip") {
+			body, err := newGzipReader(req.Body)
+			if err != nil {
+				log.Printf("failed to decompress request body: %v", err)
+				http.Error(w, "incorrect gzip body", http.StatusBadRequest)
+				return
+			}
+			req.Body = http.MaxBytesReader(w, body, maxBodySize)
+			req.Header.Del("Content-Encoding")
+			req.ContentLength = -1
+		}
+		if !acceptsGzip(req) {
+			next.ServeHTTP(w, req)
+			return
+		}
+		gw := newGzipWriter(w)
+		defer func() {
+			if err := gw.Close(); err != nil {
+				log.Printf("failed to finish gzip response: %v", err)
+			}
+		}()
+		next.ServeHTTP(gw, req)
+	})
+}
@@ -0,0 +1,198 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func newTestRouter(t *testing.T, metrics int) http.Handler {
+	t.Helper()
+	gaugeStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	counterStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	metricService := service.NewMetricService(gaugeStorage, counterStorage)
+	for i := range metrics {
+		metricService.SetMetricValue(&domain.SetMetricRequest{
+			MetricType:  domain.Gauge,
+			MetricName:  "someGaugeMetric" + strconv.Itoa(i),
+			MetricValue: "13.5",
+		})
+	}
+	api, err := NewAPI(metricService, &Config{})
+	require.NoError(t, err)
+	return api.srv.Handler
+}
+
+func gzipBody(t *testing.T, body string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return &buf
+}
+
+func TestGzipMiddleware(t *testing.T) {
+	type want struct {
+		statusCode      int
+		contentEncoding string
+	}
+	tests := []struct {
+		name            string
+		method          string
+		url             string
+		body            io.Reader
+		contentType     string
+		contentEncoding string
+		acceptEncoding  string
+		metrics         int
+		want            want
+	}{
+		{
+			name:            "compressedRequest",
+			method:          http.MethodPost,
+			url:             "/update/",
+			body:            gzipBody(t, `{"id":"someMetric","type":"gauge","value":13.5}`),
+			contentEncoding: "gzip",
+			acceptEncoding:  "",
+			want: want{
+				statusCode:      http.StatusOK,
+				contentEncoding: "",
+			},
+		},
+		{
+			name:            "brokenCompressedRequest",
+			method:          http.MethodPost,
+			url:             "/update/",
+			body:            bytes.NewBufferString(`{"id":"someMetric","type":"gauge","value":13.5}`),
+			contentEncoding: "gzip",
+			acceptEncoding:  "",
+			want: want{
+				statusCode:      http.StatusBadRequest,
+				contentEncoding: "",
+			},
+		},
+		{
+			name:            "decompressedRequestTooLarge",
+			method:          http.MethodPost,
+			url:             "/v1/metrics",
+			body:            gzipBody(t, strings.Repeat("\x00", maxBodySize+1)),
+			contentType:     "application/x-protobuf",
+			contentEncoding: "gzip",
+			want: want{
+				statusCode:      http.StatusRequestEntityTooLarge,
+				contentEncoding: "",
+			},
+		},
+		{
+			name:   "plainBatchTooLarge",
+			method: http.MethodPost,
+			url:    "/updates/",
+			body:   bytes.NewBufferString("[" + strings.Repeat(" ", maxBodySize)),
+			want: want{
+				statusCode:      http.StatusRequestEntityTooLarge,
+				contentEncoding: "",
+			},
+		},
+		{
+			name:   "plainLineProtocolTooLarge",
+			method: http.MethodPost,
+			url:    "/write",
+			body:   bytes.NewBufferString(strings.Repeat("\n", maxBodySize+1)),
+			want: want{
+				statusCode:      http.StatusRequestEntityTooLarge,
+				contentEncoding: "",
+			},
+		},
+		{
+			name:           "smallResponseNotCompressed",
+			method:         http.MethodPost,
+			url:            "/update/",
+			body:           bytes.NewBufferString(`{"id":"someMetric","type":"gauge","value":13.5}`),
+			acceptEncoding: "gzip",
+			want: want{
+				statusCode:      http.StatusOK,
+				contentEncoding: "",
+			},
+		},
+		{
+			name:           "largeHTMLResponseCompressed",
+			method:         http.MethodGet,
+			url:            "/",
+			body:           http.NoBody,
+			acceptEncoding: "gzip, deflate",
+			metrics:        100,
+			want: want{
+				statusCode:      http.StatusOK,
+				contentEncoding: "gzip",
+			},
+		},
+		{
+			name:           "largeHTMLResponseGzipRefused",
+			method:         http.MethodGet,
+			url:            "/",
+			body:           http.NoBody,
+			acceptEncoding: "gzip;q=0, deflate",
+			metrics:        100,
+			want: want{
+				statusCode:      http.StatusOK,
+				contentEncoding: "",
+			},
+		},
+		{
+			name:           "largeHTMLResponseWithoutAcceptEncoding",
+			method:         http.MethodGet,
+			url:            "/",
+			body:           http.NoBody,
+			acceptEncoding: "",
+			metrics:        100,
+			want: want{
+				statusCode:      http.StatusOK,
+				contentEncoding: "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.url, tt.body)
+			r.Header.Set("Content-Type", tt.contentType)
+			r.Header.Set("Content-Encoding", tt.contentEncoding)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			newTestRouter(t, tt.metrics).ServeHTTP(w, r)
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.want.statusCode, result.StatusCode)
+			assert.Equal(t, tt.want.contentEncoding, result.Header.Get("Content-Encoding"))
+			if tt.want.contentEncoding == "gzip" {
+				zr, err := gzip.NewReader(result.Body)
+				require.NoError(t, err)
+				body, err := io.ReadAll(zr)
+				require.NoError(t, err)
+				assert.Contains(t, string(body), "someGaugeMetric99: 13.5")
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+)
+
+const hashHeader = "HashSHA256"
+
+func sign(data []byte, key []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashResponseWriter holds the response back until the handler is done, so
+// the signature header can be computed over the complete body.
+type hashResponseWriter struct {
+	http.ResponseWriter
+	buf        bytes.Buffer
+	statusCode int
+}
+
+func (w *hashResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+func (w *hashResponseWriter) Write(p []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.buf.Write(p) //nolint:wrapcheck // bytes.Buffer never fails
+}
+
+// hashMiddleware checks the HMAC-SHA256 signature of request bodies and signs
+// responses with the same key. Signatures cover the uncompressed payload, so
+// the middleware runs after gzip decoding. It guards the agent and admin routes.
+func hashMiddleware(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if key == "" {
+			return next
+		}
+		secret := []byte(key)
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				log.Printf("failed to read request body: %v", err)
+				http.Error(w, "failed to read body", bodyErrorStatus(err))
+				return
+			}
+			if !hmac.Equal([]byte(req.Header.Get(hashHeader)), []byte(sign(body, secret))) {
+				log.Printf("signature mismatch for %s %s", req.Method, req.RequestURI)
+				http.Error(w, "signature mismatch", http.StatusBadRequest)
+				return
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			hw := &hashResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(hw, req)
+			w.Header().Set(hashHeader, sign(hw.buf.Bytes(), secret))
+			if hw.statusCode != 0 {
+				w.WriteHeader(hw.statusCode)
+			}
+			if _, err = w.Write(hw.buf.Bytes()); err != nil {
+				log.Printf("failed to write signed response: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func TestHashMiddleware(t *testing.T) {
+	const key = "secret"
+	body := `{"id":"someMetric","type":"gauge","value":13.5}`
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		hash       string
+		statusCode int
+		signed     bool
+	}{
+		{
+			name:       "validSignature",
+			method:     http.MethodPost,
+			url:        "/update/",
+			body:       body,
+			hash:       sign([]byte(body), []byte(key)),
+			statusCode: http.StatusOK,
+			signed:     true,
+		},
+		{
+			name:       "invalidSignature",
+			method:     http.MethodPost,
+			url:        "/update/",
+			body:       body,
+			hash:       sign([]byte(body), []byte("other")),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "missingSignature",
+			method:     http.MethodPost,
+			url:        "/update/gauge/someMetric/13.5",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "deleteWithoutSignature",
+			method:     http.MethodDelete,
+			url:        "/value/gauge/someMetric",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "resetWithoutSignature",
+			method:     http.MethodPost,
+			url:        "/reset/counter/someMetric",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "signedDelete",
+			method:     http.MethodDelete,
+			url:        "/value/gauge/someMetric",
+			hash:       sign(nil, []byte(key)),
+			statusCode: http.StatusNotFound,
+			signed:     true,
+		},
+		{
+			name:       "readOnlyWithoutSignature",
+			method:     http.MethodGet,
+			url:        "/",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "lookupWithoutSignature",
+			method:     http.MethodPost,
+			url:        "/value/",
+			body:       `{"id":"someMetric","type":"gauge"}`,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "influxWithoutSignature",
+			method:     http.MethodPost,
+			url:        "/write",
+			body:       "cpu value=0.5",
+			statusCode: http.StatusNoContent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gaugeStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			counterStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			metricService := service.NewMetricService(gaugeStorage, counterStorage)
+			api, err := NewAPI(metricService, &Config{Key: key})
+			require.NoError(t, err)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+			if tt.hash != "" {
+				r.Header.Set(hashHeader, tt.hash)
+			}
+			api.srv.Handler.ServeHTTP(w, r)
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			if tt.signed {
+				responseBody, err := io.ReadAll(result.Body)
+				require.NoError(t, err)
+				assert.Equal(t, sign(responseBody, []byte(key)), result.Header.Get(hashHeader))
+			}
+		})
+	}
+}
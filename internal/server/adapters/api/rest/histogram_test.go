@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func TestHandler_Histogram(t *testing.T) {
+	h := handler{
+		metricService: newTestMetricService(t, service.WithHistogramStorage(
+			newTestStorage(t, &memory.Config{Buckets: []float64{0.1, 0.5}}),
+		)),
+	}
+	for _, value := range []string{"0.05", "0.3"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/update/histogram/latency/"+value+"?path=/a", http.NoBody)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("metricType", domain.Histogram)
+		rctx.URLParams.Add("metricName", "latency")
+		rctx.URLParams.Add("metricValue", value)
+		h.SetMetricValue(w, r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx)))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	h.SetMetricValueJSON(w, httptest.NewRequest(http.MethodPost, "/update/",
+		bytes.NewBufferString(`{"id":"latency","type":"histogram","value":2,"labels":{"path":"/a"}}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":"latency","type":"histogram","labels":{"path":"/a"},"histogram":{`+
+		`"buckets":[{"le":0.1,"count":1},{"le":0.5,"count":2}],"sum":2.35,"count":3}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.GetAllMetrics(w, httptest.NewRequest(http.MethodGet, "/?match=__name__=latency", http.NoBody))
+	assert.Equal(t, `<html><body><ul><li>latency{path=&#34;/a&#34;}: count=3 sum=2.35 le0.1=1 le0.5=2 le+Inf=3</li>`+
+		`</ul></body></html>`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.GetPrometheusMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics?match=__name__=latency", http.NoBody))
+	assert.Equal(t, "# TYPE latency histogram\n"+
+		`latency_bucket{le="0.1",path="/a"} 1`+"\n"+
+		`latency_bucket{le="0.5",path="/a"} 2`+"\n"+
+		`latency_bucket{le="+Inf",path="/a"} 3`+"\n"+
+		`latency_sum{path="/a"} 2.35`+"\n"+
+		`latency_count{path="/a"} 3`+"\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.SetMetricValuesJSON(w, httptest.NewRequest(http.MethodPost, "/updates/",
+		bytes.NewBufferString(`[{"id":"latency","type":"histogram"}]`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "an observation needs a value")
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/update/histogram/latency/Inf?path=/a", http.NoBody)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("metricType", domain.Histogram)
+	rctx.URLParams.Add("metricName", "latency")
+	rctx.URLParams.Add("metricValue", "Inf")
+	h.SetMetricValue(w, r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx)))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "an infinite observation would break the sum")
+}
+
+func TestHandler_HistogramWithoutStorage(t *testing.T) {
+	h := handler{
+		metricService: newTestMetricService(t),
+	}
+	w := httptest.NewRecorder()
+	h.SetMetricValueJSON(w, httptest.NewRequest(http.MethodPost, "/update/",
+		bytes.NewBufferString(`{"id":"latency","type":"histogram","value":2}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+// maxRangePoints caps how many steps a single range query may ask for, the
+// same limit Prometheus applies.
+const maxRangePoints = 11000
+
+var errInvalidRangeQuery = errors.New("invalid range query")
+
+// rangePoint is the last value of a step together with its aggregates:
+// min, max and avg for gauges and the sum of the increments for counters.
+type rangePoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+	Min       *float64  `json:"min,omitempty"`
+	Max       *float64  `json:"max,omitempty"`
+	Avg       *float64  `json:"avg,omitempty"`
+	Sum       *float64  `json:"sum,omitempty"`
+}
+
+func newRangePoint(metricType string, sample domain.Sample) rangePoint {
+	point := rangePoint{
+		Timestamp: sample.Timestamp.UTC(),
+		Value:     sample.Value,
+	}
+	if metricType != domain.Gauge && metricType != domain.Set {
+		point.Sum = &sample.Sum
+		return point
+	}
+	avg := sample.Sum / float64(sample.Count)
+	point.Min, point.Max, point.Avg = &sample.Min, &sample.Max, &avg
+	return point
+}
+
+type rangeSeries struct {
+	ID     string        `json:"id"`
+	Labels domain.Labels `json:"labels,omitempty"`
+	Points []rangePoint  `json:"points"`
+}
+
+type rangeQueryResponse struct {
+	Type   string         `json:"type"`
+	Series []*rangeSeries `json:"series"`
+}
+
+// parseTimestamp accepts unix seconds with an optional fraction or RFC 3339.
+func parseTimestamp(s string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		whole, frac := math.Modf(seconds)
+		return time.Unix(int64(whole), int64(frac*float64(time.Second))), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: bad timestamp %q", errInvalidRangeQuery, s)
+	}
+	return t, nil
+}
+
+// parseStep accepts a Go duration such as 15s or a number of seconds.
+func parseStep(s string) (time.Duration, error) {
+	step, err := time.ParseDuration(s)
+	if err != nil {
+		seconds, floatErr := strconv.ParseFloat(s, 64)
+		if floatErr != nil {
+			return 0, fmt.Errorf("%w: bad step %q", errInvalidRangeQuery, s)
+		}
+		step = time.Duration(seconds * float64(time.Second))
+	}
+	if step <= 0 {
+		return 0, fmt.Errorf("%w: step must be positive", errInvalidRangeQuery)
+	}
+	return step, nil
+}
+
+func parseRangeQuery(req *http.Request) (*domain.MetricHistoryRequest, error) {
+	query := req.URL.Query()
+	request := &domain.MetricHistoryRequest{
+		MetricType: query.Get("type"),
+		MetricName: query.Get("name"),
+	}
+	if request.MetricName == "" {
+		return nil, fmt.Errorf("%w: missing name", errInvalidRangeQuery)
+	}
+	var err error
+	if request.Start, err = parseTimestamp(query.Get("start")); err != nil {
+		return nil, err
+	}
+	if request.End, err = parseTimestamp(query.Get("end")); err != nil {
+		return nil, err
+	}
+	if request.End.Before(request.Start) {
+		return nil, fmt.Errorf("%w: end is before start", errInvalidRangeQuery)
+	}
+	if query.Has("step") {
+		if request.Step, err = parseStep(query.Get("step")); err != nil {
+			return nil, err
+		}
+		if request.End.Sub(request.Start)/request.Step >= maxRangePoints {
+			return nil, fmt.Errorf("%w: more than %d points requested", errInvalidRangeQuery, maxRangePoints)
+		}
+	}
+	if request.Matchers, err = matchersFromQuery(req); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// QueryRange returns the history of every series with the given name and
+// type between start and end. With step the samples are aggregated on a grid
+// of that resolution, served from the coarsest rollup that fits, otherwise
+// the raw samples are returned. Series can be narrowed down with match
+// parameters.
+func (h *handler) QueryRange(w http.ResponseWriter, req *http.Request) {
+	request, err := parseRangeQuery(req)
+	if err != nil {
+		log.Printf("failed to parse range query: %v", err)
+		writeJSONStatus(w, http.StatusBadRequest, &errorResponse{Error: err.Error()})
+		return
+	}
+	history := h.metricService.GetMetricHistory(request)
+	if history.Error != nil {
+		log.Printf("failed to get history of %s for metricType %s: %v", request.MetricName, request.MetricType, history.Error)
+		if errors.Is(history.Error, domain.ErrIncorrectMetricType) {
+			writeJSONStatus(w, http.StatusBadRequest, &errorResponse{Error: history.Error.Error()})
+		} else {
+			writeJSONStatus(w, http.StatusInternalServerError, &errorResponse{Error: "failed to get history"})
+		}
+		return
+	}
+	response := &rangeQueryResponse{
+		Type:   request.MetricType,
+		Series: make([]*rangeSeries, 0, len(history.Series)),
+	}
+	for _, series := range history.Series {
+		points := make([]rangePoint, 0, len(series.Samples))
+		for _, sample := range series.Samples {
+			points = append(points, newRangePoint(request.MetricType, sample))
+		}
+		response.Series = append(response.Series, &rangeSeries{
+			ID:     series.Name,
+			Labels: series.Labels,
+			Points: points,
+		})
+	}
+	writeJSON(w, response)
+}
@@ -0,0 +1,195 @@
+package rest
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "unix", in: "1712345678", want: time.Unix(1712345678, 0)},
+		{name: "unixFraction", in: "1712345678.5", want: time.Unix(1712345678, int64(time.Second/2))},
+		{name: "rfc3339", in: "2024-04-05T19:34:38Z", want: time.Unix(1712345678, 0)},
+		{name: "empty", in: "", wantErr: true},
+		{name: "garbage", in: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTimestamp(tt.in)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errInvalidRangeQuery)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), got)
+		})
+	}
+}
+
+func TestHandler_QueryRange(t *testing.T) {
+	gaugeStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{Retention: time.Hour, MaxSamples: 3},
+	})
+	counterStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{Retention: time.Hour, MaxSamples: 3},
+	})
+	metricService := service.NewMetricService(gaugeStorage, counterStorage)
+	start := time.Now().Add(-time.Minute)
+	for _, metric := range []*domain.SetMetricRequest{
+		{MetricType: domain.Gauge, MetricName: "cpu", MetricValue: "0.1", Labels: domain.Labels{"host": "a"}},
+		{MetricType: domain.Gauge, MetricName: "cpu", MetricValue: "0.2", Labels: domain.Labels{"host": "a"}},
+		{MetricType: domain.Gauge, MetricName: "cpu", MetricValue: "0.3", Labels: domain.Labels{"host": "a"}},
+		{MetricType: domain.Gauge, MetricName: "cpu", MetricValue: "0.4", Labels: domain.Labels{"host": "a"}},
+		{MetricType: domain.Gauge, MetricName: "cpu", MetricValue: "0.9", Labels: domain.Labels{"host": "b"}},
+		{MetricType: domain.Counter, MetricName: "requests", MetricValue: "2"},
+		{MetricType: domain.Counter, MetricName: "requests", MetricValue: "3"},
+	} {
+		require.NoError(t, metricService.SetMetricValue(metric).Error)
+	}
+	end := time.Now().Add(time.Minute)
+	h := handler{
+		metricService: metricService,
+	}
+	window := url.Values{
+		"start": {strconv.FormatInt(start.Unix(), 10)},
+		"end":   {end.Format(time.RFC3339Nano)},
+	}
+	query := func(params url.Values) string {
+		q := url.Values{}
+		for key, values := range window {
+			q[key] = values
+		}
+		for key, values := range params {
+			q[key] = values
+		}
+		return "/query_range?" + q.Encode()
+	}
+	type series struct {
+		id     string
+		labels domain.Labels
+		values []float64
+	}
+	tests := []struct {
+		name       string
+		url        string
+		statusCode int
+		want       []series
+	}{
+		{
+			name:       "raw",
+			url:        query(url.Values{"name": {"cpu"}, "type": {domain.Gauge}}),
+			statusCode: http.StatusOK,
+			want: []series{
+				{id: "cpu", labels: domain.Labels{"host": "a"}, values: []float64{0.2, 0.3, 0.4}},
+				{id: "cpu", labels: domain.Labels{"host": "b"}, values: []float64{0.9}},
+			},
+		},
+		{
+			name:       "stepAndMatcher",
+			url:        query(url.Values{"name": {"cpu"}, "type": {domain.Gauge}, "step": {"1h"}, "match": {"host=a"}}),
+			statusCode: http.StatusOK,
+			want: []series{
+				{id: "cpu", labels: domain.Labels{"host": "a"}, values: []float64{0.4}},
+			},
+		},
+		{
+			name:       "counterTotals",
+			url:        query(url.Values{"name": {"requests"}, "type": {domain.Counter}}),
+			statusCode: http.StatusOK,
+			want: []series{
+				{id: "requests", values: []float64{2, 5}},
+			},
+		},
+		{
+			name:       "outsideRange",
+			url:        "/query_range?name=cpu&type=gauge&start=0&end=1",
+			statusCode: http.StatusOK,
+			want:       []series{},
+		},
+		{
+			name:       "unknownType",
+			url:        query(url.Values{"name": {"cpu"}, "type": {"histogram"}}),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "missingName",
+			url:        query(url.Values{"type": {domain.Gauge}}),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "missingStart",
+			url:        "/query_range?name=cpu&type=gauge&end=1",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "endBeforeStart",
+			url:        "/query_range?name=cpu&type=gauge&start=10&end=1",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "tooManyPoints",
+			url:        query(url.Values{"name": {"cpu"}, "type": {domain.Gauge}, "step": {"1ms"}}),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "badStep",
+			url:        query(url.Values{"name": {"cpu"}, "type": {domain.Gauge}, "step": {"-1"}}),
+			statusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.QueryRange(w, httptest.NewRequest(http.MethodGet, tt.url, http.NoBody))
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			require.Equal(t, tt.statusCode, result.StatusCode)
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+			var response rangeQueryResponse
+			require.NoError(t, json.NewDecoder(result.Body).Decode(&response))
+			require.Len(t, response.Series, len(tt.want))
+			for i, want := range tt.want {
+				got := response.Series[i]
+				assert.Equal(t, want.id, got.ID)
+				assert.Equal(t, want.labels, got.Labels)
+				values := make([]float64, 0, len(got.Points))
+				for _, point := range got.Points {
+					assert.False(t, point.Timestamp.Before(start.Truncate(time.Second)))
+					if response.Type == domain.Counter {
+						assert.NotNil(t, point.Sum)
+						assert.Nil(t, point.Avg)
+					} else {
+						assert.NotNil(t, point.Avg)
+						assert.Nil(t, point.Sum)
+					}
+					values = append(values, point.Value)
+				}
+				assert.Equal(t, want.values, values)
+			}
+		})
+	}
+}
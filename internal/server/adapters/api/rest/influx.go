@@ -0,0 +1,176 @@
+package rest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+var errInvalidLineProtocol = errors.New("invalid line protocol")
+
+// parseLineProtocol reads InfluxDB line protocol. Every field becomes a
+// metric named measurement.field labelled with the tags of the line. Integer
+// fields (i and u suffixes) are counters, floats are gauges, booleans are
+// gauges of 0 or 1 and string fields are skipped.
+func parseLineProtocol(r io.Reader) ([]*domain.SetMetricRequest, error) {
+	var metrics []*domain.SetMetricRequest
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parsed, err := parseInfluxLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		metrics = append(metrics, parsed...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read line protocol: %w", err)
+	}
+	return metrics, nil
+}
+
+func parseInfluxLine(line string) ([]*domain.SetMetricRequest, error) {
+	sections := splitUnescaped(line, ' ')
+	const minSections, maxSections = 2, 3
+	if len(sections) < minSections || len(sections) > maxSections {
+		return nil, fmt.Errorf("%w: expected measurement, fields and optional timestamp", errInvalidLineProtocol)
+	}
+	if len(sections) == maxSections {
+		if _, err := strconv.ParseInt(sections[2], 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: bad timestamp %q", errInvalidLineProtocol, sections[2])
+		}
+	}
+	series := splitUnescaped(sections[0], ',')
+	measurement := unescapeInflux(series[0])
+	if measurement == "" {
+		return nil, fmt.Errorf("%w: missing measurement", errInvalidLineProtocol)
+	}
+	tags := make(domain.Labels, len(series)-1)
+	for _, tag := range series[1:] {
+		kv := splitUnescaped(tag, '=')
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("%w: bad tag %q", errInvalidLineProtocol, tag)
+		}
+		tags[unescapeInflux(kv[0])] = unescapeInflux(kv[1])
+	}
+	fields := splitUnescaped(sections[1], ',')
+	metrics := make([]*domain.SetMetricRequest, 0, len(fields))
+	for _, field := range fields {
+		kv := splitUnescaped(field, '=')
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("%w: bad field %q", errInvalidLineProtocol, field)
+		}
+		metricType, metricValue, err := parseInfluxValue(kv[1])
+		if err != nil {
+			return nil, err
+		}
+		if metricType == "" {
+			continue
+		}
+		metrics = append(metrics, &domain.SetMetricRequest{
+			MetricType:  metricType,
+			MetricName:  measurement + "." + unescapeInflux(kv[0]),
+			MetricValue: metricValue,
+			Labels:      tags,
+		})
+	}
+	return metrics, nil
+}
+
+// parseInfluxValue returns an empty metric type for string fields, which
+// have no numeric representation.
+func parseInfluxValue(value string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		if len(value) < 2 || !strings.HasSuffix(value, `"`) {
+			return "", "", fmt.Errorf("%w: unterminated string %q", errInvalidLineProtocol, value)
+		}
+		return "", "", nil
+	case strings.HasSuffix(value, "i"), strings.HasSuffix(value, "u"):
+		delta, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: bad integer %q", errInvalidLineProtocol, value)
+		}
+		return domain.Counter, strconv.FormatInt(delta, 10), nil
+	}
+	switch value {
+	case "t", "T", "true", "True", "TRUE":
+		return domain.Gauge, "1", nil
+	case "f", "F", "false", "False", "FALSE":
+		return domain.Gauge, "0", nil
+	}
+	gauge, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: bad float %q", errInvalidLineProtocol, value)
+	}
+	return domain.Gauge, strconv.FormatFloat(gauge, 'f', -1, 64), nil
+}
+
+// splitUnescaped splits s on sep, ignoring separators escaped with a
+// backslash or enclosed in double quotes.
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescapeInflux(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// WriteInfluxMetrics is the InfluxDB 1.x /write endpoint used by Telegraf and
+// similar agents. The whole body is stored as one batch or rejected.
+func (h *handler) WriteInfluxMetrics(w http.ResponseWriter, req *http.Request) {
+	metrics, err := parseLineProtocol(req.Body)
+	if err != nil {
+		log.Printf("failed to parse line protocol: %v", err)
+		writeJSONStatus(w, bodyErrorStatus(err), &errorResponse{Error: err.Error()})
+		return
+	}
+	if len(metrics) > 0 {
+		if err = h.metricService.SetMetricValues(&domain.SetMetricsRequest{Metrics: metrics}).Error; err != nil {
+			log.Printf("failed to set batch of %d metrics: %v", len(metrics), err)
+			if errors.Is(err, domain.ErrIncorrectMetricValue) {
+				writeJSONStatus(w, http.StatusBadRequest, &errorResponse{Error: err.Error()})
+				return
+			}
+			writeJSONStatus(w, http.StatusInternalServerError, &errorResponse{Error: "failed to store metrics"})
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
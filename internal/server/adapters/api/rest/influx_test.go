@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func TestParseLineProtocol(t *testing.T) {
+	tags := domain.Labels{"host": "web01", "region": "eu"}
+	pathTags := domain.Labels{"path": "/var log"}
+	tests := []struct {
+		name    string
+		body    string
+		want    []*domain.SetMetricRequest
+		wantErr bool
+	}{
+		{
+			name: "fieldsAndTags",
+			body: "cpu,host=web01,region=eu usage_idle=92.5,procs=12i,online=true 1712345678000000000\n",
+			want: []*domain.SetMetricRequest{
+				{MetricType: domain.Gauge, MetricName: "cpu.usage_idle", MetricValue: "92.5", Labels: tags},
+				{MetricType: domain.Counter, MetricName: "cpu.procs", MetricValue: "12", Labels: tags},
+				{MetricType: domain.Gauge, MetricName: "cpu.online", MetricValue: "1", Labels: tags},
+			},
+		},
+		{
+			name: "escapesAndStrings",
+			body: "# comment\n\ndisk\\ io,path=/var\\ log reads=3u,label=\"a, b=c\",util=0.5\n",
+			want: []*domain.SetMetricRequest{
+				{MetricType: domain.Counter, MetricName: "disk io.reads", MetricValue: "3", Labels: pathTags},
+				{MetricType: domain.Gauge, MetricName: "disk io.util", MetricValue: "0.5", Labels: pathTags},
+			},
+		},
+		{name: "missingFields", body: "cpu\n", wantErr: true},
+		{name: "badInteger", body: "cpu procs=1.5i\n", wantErr: true},
+		{name: "badFloat", body: "cpu usage=abc\n", wantErr: true},
+		{name: "badTimestamp", body: "cpu usage=1 yesterday\n", wantErr: true},
+		{name: "badTag", body: "cpu,host usage=1\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLineProtocol(strings.NewReader(tt.body))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHandler_WriteInfluxMetrics(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		statusCode int
+		counter    string
+	}{
+		{
+			name:       "stored",
+			body:       "jobs processed=3i\njobs processed=4i\n",
+			statusCode: http.StatusNoContent,
+			counter:    "7",
+		},
+		{
+			name:       "rejectedOnParseError",
+			body:       "jobs processed=3i\njobs processed=\n",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gaugeStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			counterStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			metricService := service.NewMetricService(gaugeStorage, counterStorage)
+			h := handler{
+				metricService: metricService,
+			}
+			w := httptest.NewRecorder()
+			h.WriteInfluxMetrics(w, httptest.NewRequest(http.MethodPost, "/write", bytes.NewBufferString(tt.body)))
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			counter := metricService.GetMetricValue(&domain.MetricRequest{
+				MetricType: domain.Counter,
+				MetricName: "jobs.processed",
+			})
+			assert.Equal(t, tt.counter, counter.MetricValue)
+		})
+	}
+}
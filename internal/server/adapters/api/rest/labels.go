@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+// matchParam is the query parameter list endpoints read label matchers from,
+// e.g. ?match=host="a"&match=region=~"eu-.*". It may be repeated.
+const matchParam = "match"
+
+// quantileParam asks /value for a quantile of a summary, e.g. ?q=0.99. It is
+// reserved and never read as a label.
+const quantileParam = "q"
+
+// labelsFromQuery takes the labels of a series addressed by URL from the query
+// string, so /update/gauge/cpu/0.5?host=a updates the cpu{host="a"} series.
+func labelsFromQuery(req *http.Request) domain.Labels {
+	query := req.URL.Query()
+	query.Del(quantileParam)
+	if len(query) == 0 {
+		return nil
+	}
+	labels := make(domain.Labels, len(query))
+	for key, values := range query {
+		labels[key] = values[0]
+	}
+	return labels
+}
+
+func matchersFromQuery(req *http.Request) ([]*domain.LabelMatcher, error) {
+	values := req.URL.Query()[matchParam]
+	matchers := make([]*domain.LabelMatcher, 0, len(values))
+	for _, value := range values {
+		matcher, err := parseLabelMatcher(value)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
+
+// parseLabelMatcher reads name<op>value where op is one of =, !=, =~ and !~.
+// The value may be a double quoted Go string.
+func parseLabelMatcher(s string) (*domain.LabelMatcher, error) {
+	i := strings.IndexAny(s, "=!")
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %q has no operator", domain.ErrIncorrectLabelMatcher, s)
+	}
+	name, rest := strings.TrimSpace(s[:i]), s[i:]
+	var matchType string
+	for _, op := range []string{domain.MatchRegexp, domain.MatchNotRegexp, domain.MatchNotEqual, domain.MatchEqual} {
+		if strings.HasPrefix(rest, op) {
+			matchType = op
+			break
+		}
+	}
+	if matchType == "" {
+		return nil, fmt.Errorf("%w: %q has no operator", domain.ErrIncorrectLabelMatcher, s)
+	}
+	value := strings.TrimSpace(rest[len(matchType):])
+	if strings.HasPrefix(value, `"`) {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad value in %q", domain.ErrIncorrectLabelMatcher, s)
+		}
+		value = unquoted
+	}
+	return domain.NewLabelMatcher(matchType, name, value) //nolint:wrapcheck // already a matcher error
+}
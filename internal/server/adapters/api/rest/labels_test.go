@@ -0,0 +1,257 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func TestParseLabelMatcher(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		matchType string
+		label     string
+		value     string
+		wantErr   bool
+	}{
+		{name: "equal", in: "host=web01", matchType: domain.MatchEqual, label: "host", value: "web01"},
+		{name: "quoted", in: `host="web 01"`, matchType: domain.MatchEqual, label: "host", value: "web 01"},
+		{name: "notEqual", in: "host!=web01", matchType: domain.MatchNotEqual, label: "host", value: "web01"},
+		{name: "regexp", in: `region=~"eu-.*"`, matchType: domain.MatchRegexp, label: "region", value: "eu-.*"},
+		{name: "notRegexp", in: "region!~us-.*", matchType: domain.MatchNotRegexp, label: "region", value: "us-.*"},
+		{name: "name", in: "__name__=cpu", matchType: domain.MatchEqual, label: "__name__", value: "cpu"},
+		{name: "noOperator", in: "host", wantErr: true},
+		{name: "noName", in: "=web01", wantErr: true},
+		{name: "badRegexp", in: "host=~(", wantErr: true},
+		{name: "badQuotes", in: `host="web01`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLabelMatcher(tt.in)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, domain.ErrIncorrectLabelMatcher)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.matchType, got.Type)
+			assert.Equal(t, tt.label, got.Name)
+			assert.Equal(t, tt.value, got.Value)
+		})
+	}
+}
+
+func TestHandler_SetMetricValueWithLabels(t *testing.T) {
+	metricService := newTestMetricService(t)
+	h := handler{
+		metricService: metricService,
+	}
+	values := map[string]string{"web01": "0.5", "web02": "0.75"}
+	for host, value := range values {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/update/gauge/cpu/"+value+"?host="+host, http.NoBody)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("metricType", domain.Gauge)
+		rctx.URLParams.Add("metricName", "cpu")
+		rctx.URLParams.Add("metricValue", value)
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+		h.SetMetricValue(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	for host, want := range values {
+		response := metricService.GetMetricValue(&domain.MetricRequest{
+			MetricType: domain.Gauge,
+			MetricName: "cpu",
+			Labels:     domain.Labels{"host": host},
+		})
+		assert.True(t, response.Found)
+		assert.Equal(t, want, response.MetricValue)
+	}
+	response := metricService.GetMetricValue(&domain.MetricRequest{
+		MetricType: domain.Gauge,
+		MetricName: "cpu",
+	})
+	assert.False(t, response.Found)
+}
+
+func TestHandler_MetricValueJSONWithLabels(t *testing.T) {
+	metricService := newTestMetricService(t)
+	h := handler{
+		metricService: metricService,
+	}
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		body       string
+		statusCode int
+		want       string
+	}{
+		{
+			name:       "update",
+			handler:    h.SetMetricValueJSON,
+			body:       `{"id":"requests","type":"counter","delta":3,"labels":{"path":"/a"}}`,
+			statusCode: http.StatusOK,
+			want:       `{"id":"requests","type":"counter","delta":3,"labels":{"path":"/a"}}`,
+		},
+		{
+			name:       "updateAgain",
+			handler:    h.SetMetricValueJSON,
+			body:       `{"id":"requests","type":"counter","delta":2,"labels":{"path":"/a"}}`,
+			statusCode: http.StatusOK,
+			want:       `{"id":"requests","type":"counter","delta":5,"labels":{"path":"/a"}}`,
+		},
+		{
+			name:       "value",
+			handler:    h.GetMetricValueJSON,
+			body:       `{"id":"requests","type":"counter","labels":{"path":"/a"}}`,
+			statusCode: http.StatusOK,
+			want:       `{"id":"requests","type":"counter","delta":5,"labels":{"path":"/a"}}`,
+		},
+		{
+			name:       "valueOtherSeries",
+			handler:    h.GetMetricValueJSON,
+			body:       `{"id":"requests","type":"counter","labels":{"path":"/b"}}`,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "badLabelName",
+			handler:    h.SetMetricValueJSON,
+			body:       `{"id":"requests","type":"counter","delta":1,"labels":{"a=b":"c"}}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "nameLikeSeries",
+			handler:    h.SetMetricValueJSON,
+			body:       `{"id":"requests{path=\"/a\"}","type":"counter","delta":100}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "valueAfterNameLikeSeries",
+			handler:    h.GetMetricValueJSON,
+			body:       `{"id":"requests","type":"counter","labels":{"path":"/a"}}`,
+			statusCode: http.StatusOK,
+			want:       `{"id":"requests","type":"counter","delta":5,"labels":{"path":"/a"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(tt.body)))
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			if tt.want != "" {
+				body, err := io.ReadAll(result.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, tt.want, string(body))
+			}
+		})
+	}
+}
+
+func TestHandler_GetAllMetricsWithMatchers(t *testing.T) {
+	metricService := newTestMetricService(t)
+	for _, metric := range []*domain.SetMetricRequest{
+		{MetricType: domain.Gauge, MetricName: "cpu", MetricValue: "0.5", Labels: domain.Labels{"host": "web01"}},
+		{MetricType: domain.Gauge, MetricName: "cpu", MetricValue: "0.75", Labels: domain.Labels{"host": "db01"}},
+	} {
+		require.NoError(t, metricService.SetMetricValue(metric).Error)
+	}
+	h := handler{
+		metricService: metricService,
+	}
+	tests := []struct {
+		name       string
+		url        string
+		statusCode int
+		want       string
+	}{
+		{
+			name:       "all",
+			url:        "/",
+			statusCode: http.StatusOK,
+			want: `<html><body><ul><li>cpu{host=&#34;db01&#34;}: 0.75</li><li>cpu{host=&#34;web01&#34;}: 0.5</li>` +
+				`<li>gaugeMetric: 1.25</li><li>counterMetric: 5</li></ul></body></html>`,
+		},
+		{
+			name:       "regexp",
+			url:        `/?match=host=~"web.*"`,
+			statusCode: http.StatusOK,
+			want:       `<html><body><ul><li>cpu{host=&#34;web01&#34;}: 0.5</li></ul></body></html>`,
+		},
+		{
+			name:       "unlabelled",
+			url:        `/?match=host=""&match=__name__!=gaugeMetric`,
+			statusCode: http.StatusOK,
+			want:       `<html><body><ul><li>counterMetric: 5</li></ul></body></html>`,
+		},
+		{
+			name:       "badMatcher",
+			url:        "/?match=host",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.GetAllMetrics(w, httptest.NewRequest(http.MethodGet, tt.url, http.NoBody))
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			if tt.want != "" {
+				body, err := io.ReadAll(result.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, string(body))
+			}
+		})
+	}
+}
+
+func TestHandler_GetAllMetricsFlagsStale(t *testing.T) {
+	gaugeStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{StaleAfter: time.Nanosecond, TTL: time.Hour},
+	})
+	counterStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	metricService := service.NewMetricService(gaugeStorage, counterStorage)
+	require.NoError(t, metricService.SetMetricValue(&domain.SetMetricRequest{
+		MetricType:  domain.Gauge,
+		MetricName:  "cpu",
+		MetricValue: "0.5",
+	}).Error)
+	require.NoError(t, metricService.SetMetricValue(&domain.SetMetricRequest{
+		MetricType:  domain.Counter,
+		MetricName:  "requests",
+		MetricValue: "1",
+	}).Error)
+	time.Sleep(time.Millisecond)
+	h := handler{
+		metricService: metricService,
+	}
+	w := httptest.NewRecorder()
+	h.GetAllMetrics(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Regexp(t, `<li>cpu: 0.5 \(stale, last updated \d{4}-\d{2}-\d{2}T[^)]+\)</li><li>requests: 1</li>`,
+		w.Body.String())
+}
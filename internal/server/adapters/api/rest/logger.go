@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// newLogger builds the request logger described by cfg. Values are expected
+// to be validated by NewConfig, so anything unknown falls back to info/text.
+func newLogger(cfg *Config, w io.Writer) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	if cfg.LogFormat == logFormatJSON {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	size       int
+}
+
+func (w *loggingResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *loggingResponseWriter) Write(p []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += n
+	return n, err //nolint:wrapcheck // the writer is a transparent wrapper
+}
+
+func loggingMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			lw := &loggingResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(lw, req)
+			if lw.statusCode == 0 {
+				lw.statusCode = http.StatusOK
+			}
+			logger.LogAttrs(
+				req.Context(),
+				slog.LevelInfo,
+				"request served",
+				slog.String("method", req.Method),
+				slog.String("uri", req.RequestURI),
+				slog.Int("status", lw.statusCode),
+				slog.Int("size", lw.size),
+				slog.Duration("latency", time.Since(start)),
+			)
+		})
+	}
+}
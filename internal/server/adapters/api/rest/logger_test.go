@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggingMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        Config
+		statusCode int
+		body       string
+		logged     bool
+	}{
+		{
+			name:       "jsonInfo",
+			cfg:        Config{LogLevel: "info", LogFormat: logFormatJSON},
+			statusCode: http.StatusCreated,
+			body:       "created",
+			logged:     true,
+		},
+		{
+			name:       "implicitStatusOk",
+			cfg:        Config{LogLevel: "debug", LogFormat: logFormatJSON},
+			statusCode: 0,
+			body:       "ok",
+			logged:     true,
+		},
+		{
+			name:       "filteredByLevel",
+			cfg:        Config{LogLevel: "warn", LogFormat: logFormatJSON},
+			statusCode: http.StatusOK,
+			body:       "ok",
+			logged:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			handler := loggingMiddleware(newLogger(&tt.cfg, &out))(
+				http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+					if tt.statusCode != 0 {
+						w.WriteHeader(tt.statusCode)
+					}
+					_, _ = w.Write([]byte(tt.body))
+				}),
+			)
+			r := httptest.NewRequest(http.MethodGet, "/value/gauge/someMetric", http.NoBody)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+			if !tt.logged {
+				assert.Empty(t, out.String())
+				return
+			}
+			var record map[string]any
+			require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+			expectedStatus := tt.statusCode
+			if expectedStatus == 0 {
+				expectedStatus = http.StatusOK
+			}
+			assert.Equal(t, http.MethodGet, record["method"])
+			assert.Equal(t, "/value/gauge/someMetric", record["uri"])
+			assert.InDelta(t, expectedStatus, record["status"], 0)
+			assert.InDelta(t, len(tt.body), record["size"], 0)
+			assert.Contains(t, record, "latency")
+		})
+	}
+}
+
+func TestValidateLogConfig(t *testing.T) {
+	assert.NoError(t, validateLogConfig(&Config{LogLevel: "debug", LogFormat: logFormatText}))
+	assert.Error(t, validateLogConfig(&Config{LogLevel: "verbose", LogFormat: logFormatText}))
+	assert.Error(t, validateLogConfig(&Config{LogLevel: "info", LogFormat: "xml"}))
+}
@@ -0,0 +1,204 @@
+package rest
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+const (
+	otlpProtobufContentType = "application/x-protobuf"
+	otlpJSONContentType     = "application/json"
+)
+
+type otlpConverter struct {
+	sums     *totalsTx
+	metrics  []*domain.SetMetricRequest
+	rejected int64
+}
+
+func (c *otlpConverter) convert(request *colmetricspb.ExportMetricsServiceRequest) {
+	for _, resourceMetrics := range request.GetResourceMetrics() {
+		resource := otlpAttributes(nil, resourceMetrics.GetResource().GetAttributes())
+		for _, scopeMetrics := range resourceMetrics.GetScopeMetrics() {
+			for _, metric := range scopeMetrics.GetMetrics() {
+				c.convertMetric(resource, metric)
+			}
+		}
+	}
+}
+
+func (c *otlpConverter) convertMetric(resource domain.Labels, metric *metricspb.Metric) {
+	switch data := metric.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		for _, point := range data.Gauge.GetDataPoints() {
+			c.metrics = append(c.metrics, &domain.SetMetricRequest{
+				MetricType:  domain.Gauge,
+				MetricName:  metric.GetName(),
+				MetricValue: strconv.FormatFloat(otlpValue(point), 'f', -1, 64),
+				Labels:      otlpAttributes(resource, point.GetAttributes()),
+			})
+		}
+	case *metricspb.Metric_Sum:
+		cumulative := data.Sum.GetAggregationTemporality() ==
+			metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+		for _, point := range data.Sum.GetDataPoints() {
+			labels := otlpAttributes(resource, point.GetAttributes())
+			if !data.Sum.GetIsMonotonic() {
+				// An up-down counter can go below zero, which a counter can't,
+				// so its running total is stored as a gauge.
+				total, _ := c.sums.advance(domain.Gauge, metric.GetName(), labels, otlpValue(point), cumulative)
+				c.metrics = append(c.metrics, &domain.SetMetricRequest{
+					MetricType:  domain.Gauge,
+					MetricName:  metric.GetName(),
+					MetricValue: strconv.FormatFloat(total, 'f', -1, 64),
+					Labels:      labels,
+				})
+				continue
+			}
+			_, delta := c.sums.advance(domain.Counter, metric.GetName(), labels, otlpValue(point), cumulative)
+			c.metrics = append(c.metrics, &domain.SetMetricRequest{
+				MetricType:  domain.Counter,
+				MetricName:  metric.GetName(),
+				MetricValue: strconv.FormatInt(delta, 10),
+				Labels:      labels,
+			})
+		}
+	case *metricspb.Metric_Histogram:
+		c.rejected += int64(len(data.Histogram.GetDataPoints()))
+	case *metricspb.Metric_ExponentialHistogram:
+		c.rejected += int64(len(data.ExponentialHistogram.GetDataPoints()))
+	case *metricspb.Metric_Summary:
+		c.rejected += int64(len(data.Summary.GetDataPoints()))
+	}
+}
+
+func otlpValue(point *metricspb.NumberDataPoint) float64 {
+	if value, ok := point.GetValue().(*metricspb.NumberDataPoint_AsInt); ok {
+		return float64(value.AsInt)
+	}
+	return point.GetAsDouble()
+}
+
+// otlpAttributes merges data point attributes over the resource ones, so a
+// series keeps the identity of the service that produced it.
+func otlpAttributes(resource domain.Labels, attributes []*commonpb.KeyValue) domain.Labels {
+	labels := make(domain.Labels, len(resource)+len(attributes))
+	for key, value := range resource {
+		labels[key] = value
+	}
+	for _, attribute := range attributes {
+		labels[attribute.GetKey()] = otlpAttributeValue(attribute.GetValue())
+	}
+	return labels
+}
+
+func otlpAttributeValue(value *commonpb.AnyValue) string {
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'f', -1, 64)
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *commonpb.AnyValue_BytesValue:
+		return hex.EncodeToString(v.BytesValue)
+	default:
+		data, err := protojson.Marshal(value)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
+
+// ReceiveOTLPMetrics implements the OTLP/HTTP metrics receiver for both the
+// protobuf and the JSON encoding. Histograms and summaries are not supported
+// yet and are reported back as rejected data points.
+func (h *handler) ReceiveOTLPMetrics(w http.ResponseWriter, req *http.Request) {
+	contentType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || (contentType != otlpProtobufContentType && contentType != otlpJSONContentType) {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		log.Printf("failed to read otlp request: %v", err)
+		http.Error(w, "failed to read body", bodyErrorStatus(err))
+		return
+	}
+	request := &colmetricspb.ExportMetricsServiceRequest{}
+	if contentType == otlpJSONContentType {
+		err = protojson.Unmarshal(body, request)
+	} else {
+		err = proto.Unmarshal(body, request)
+	}
+	if err != nil {
+		log.Printf("failed to decode otlp request: %v", err)
+		http.Error(w, "failed to decode otlp request", http.StatusBadRequest)
+		return
+	}
+	converter := &otlpConverter{}
+	err = h.otlpSums.update(func(tx *totalsTx) error {
+		converter.sums = tx
+		converter.convert(request)
+		if len(converter.metrics) == 0 {
+			return nil
+		}
+		return h.metricService.SetMetricValues(&domain.SetMetricsRequest{Metrics: converter.metrics}).Error
+	})
+	if err != nil {
+		log.Printf("failed to set batch of %d otlp metrics: %v", len(converter.metrics), err)
+		if errors.Is(err, domain.ErrIncorrectMetricValue) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to store metrics", http.StatusInternalServerError)
+		return
+	}
+	response := &colmetricspb.ExportMetricsServiceResponse{}
+	if converter.rejected > 0 {
+		response.PartialSuccess = &colmetricspb.ExportMetricsPartialSuccess{
+			RejectedDataPoints: converter.rejected,
+			ErrorMessage:       "histogram and summary metrics are not supported",
+		}
+	}
+	if err = writeOTLPResponse(w, contentType, response); err != nil {
+		log.Printf("failed to write otlp response: %v", err)
+	}
+}
+
+func writeOTLPResponse(w http.ResponseWriter, contentType string, response proto.Message) error {
+	var (
+		data []byte
+		err  error
+	)
+	if contentType == otlpJSONContentType {
+		data, err = protojson.Marshal(response)
+	} else {
+		data, err = proto.Marshal(response)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+	w.Header().Set("Content-Type", contentType)
+	if _, err = w.Write(data); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return nil
+}
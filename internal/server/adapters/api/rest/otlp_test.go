@@ -0,0 +1,177 @@
+package rest
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func otlpSum(name string, value float64, temporality metricspb.AggregationTemporality) *metricspb.Metric {
+	return &metricspb.Metric{
+		Name: name,
+		Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			AggregationTemporality: temporality,
+			IsMonotonic:            true,
+			DataPoints: []*metricspb.NumberDataPoint{
+				{Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: value}},
+			},
+		}},
+	}
+}
+
+func otlpRequest(metrics ...*metricspb.Metric) *colmetricspb.ExportMetricsServiceRequest {
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{{
+				Key:   "service.name",
+				Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "checkout"}},
+			}}},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: metrics}},
+		}},
+	}
+}
+
+func TestHandler_ReceiveOTLPMetrics(t *testing.T) {
+	gaugeStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	counterStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	metricService := service.NewMetricService(gaugeStorage, counterStorage)
+	h := handler{
+		metricService: metricService,
+		otlpSums:      newRunningTotals(metricService),
+	}
+	cumulative := metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	delta := metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	queue := &metricspb.Metric{
+		Name: "queue.size",
+		Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{
+			DataPoints: []*metricspb.NumberDataPoint{{
+				Attributes: []*commonpb.KeyValue{{
+					Key:   "queue",
+					Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 1}},
+				}},
+				Value: &metricspb.NumberDataPoint_AsInt{AsInt: 12},
+			}},
+		}},
+	}
+	latency := &metricspb.Metric{
+		Name: "latency",
+		Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			DataPoints: []*metricspb.HistogramDataPoint{{Count: 1}},
+		}},
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		request     *colmetricspb.ExportMetricsServiceRequest
+		rejected    int64
+	}{
+		{
+			name:        "protobuf",
+			contentType: otlpProtobufContentType,
+			request: otlpRequest(
+				queue,
+				otlpSum("requests", 10.5, cumulative),
+				otlpSum("bytes", 2.5, delta),
+			),
+		},
+		{
+			name:        "json",
+			contentType: otlpJSONContentType,
+			request: otlpRequest(
+				otlpSum("requests", 13.25, cumulative),
+				otlpSum("bytes", 1, delta),
+				latency,
+			),
+			rejected: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				body []byte
+				err  error
+			)
+			if tt.contentType == otlpJSONContentType {
+				body, err = protojson.Marshal(tt.request)
+			} else {
+				body, err = proto.Marshal(tt.request)
+			}
+			require.NoError(t, err)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/v1/metrics", bytes.NewReader(body))
+			r.Header.Set("Content-Type", tt.contentType)
+			h.ReceiveOTLPMetrics(w, r)
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			require.Equal(t, http.StatusOK, result.StatusCode)
+			assert.Equal(t, tt.contentType, result.Header.Get("Content-Type"))
+			data, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			response := &colmetricspb.ExportMetricsServiceResponse{}
+			if tt.contentType == otlpJSONContentType {
+				require.NoError(t, protojson.Unmarshal(data, response))
+			} else {
+				require.NoError(t, proto.Unmarshal(data, response))
+			}
+			assert.Equal(t, tt.rejected, response.GetPartialSuccess().GetRejectedDataPoints())
+		})
+	}
+
+	checkout := domain.Labels{"service.name": "checkout"}
+	expected := []struct {
+		metricType string
+		name       string
+		labels     domain.Labels
+		value      string
+	}{
+		{domain.Gauge, "queue.size", domain.Labels{"queue": "1", "service.name": "checkout"}, "12"},
+		{domain.Counter, "requests", checkout, "13"},
+		{domain.Counter, "bytes", checkout, "3"},
+	}
+	for _, e := range expected {
+		response := metricService.GetMetricValue(&domain.MetricRequest{
+			MetricType: e.metricType,
+			MetricName: e.name,
+			Labels:     e.labels,
+		})
+		assert.Equal(t, e.value, response.MetricValue, e.name)
+	}
+}
+
+func TestHandler_ReceiveOTLPMetricsUnsupportedContentType(t *testing.T) {
+	h := handler{otlpSums: newRunningTotals(nil)}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/v1/metrics", bytes.NewBufferString("{}"))
+	r.Header.Set("Content-Type", "text/plain")
+	h.ReceiveOTLPMetrics(w, r)
+	result := w.Result()
+	defer func() {
+		err := result.Body.Close()
+		log.Print("error occurred body close: %w", err)
+	}()
+	assert.Equal(t, http.StatusUnsupportedMediaType, result.StatusCode)
+}
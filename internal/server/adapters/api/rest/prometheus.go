@@ -0,0 +1,238 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// sanitizeMetricName maps a stored name onto the Prometheus metric name
+// alphabet [a-zA-Z_:][a-zA-Z0-9_:]*, replacing anything else with '_'.
+func sanitizeMetricName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+func prometheusName(metricType, metricName string) string {
+	name := sanitizeMetricName(metricName)
+	if metricType == domain.Counter && !strings.HasSuffix(name, "_total") {
+		name += "_total"
+	}
+	return name
+}
+
+// prometheusType maps a metric type onto the Prometheus one. A set is exposed
+// as a gauge of its estimated cardinality.
+func prometheusType(metricType string) string {
+	if metricType == domain.Set {
+		return domain.Gauge
+	}
+	return metricType
+}
+
+// sanitizeLabelName maps a label name onto [a-zA-Z_][a-zA-Z0-9_]*, so OTLP
+// attributes such as service.name are exposed as service_name.
+func sanitizeLabelName(name string) string {
+	return strings.ReplaceAll(sanitizeMetricName(name), ":", "_")
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func prometheusLabels(labels domain.Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, sanitizeLabelName(name)+`="`+labelValueReplacer.Replace(labels[name])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// GetPrometheusMetrics exposes every series grouped by metric family. The
+// match query parameter narrows the output down like a federation endpoint.
+func (h *handler) GetPrometheusMetrics(w http.ResponseWriter, req *http.Request) {
+	matchers, err := matchersFromQuery(req)
+	if err != nil {
+		log.Printf("failed to parse label matchers: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var families []string
+	samples := make(map[string]*bytes.Buffer)
+	familyTypes := make(map[string]string)
+	written := make(map[string]bool)
+	for _, metricType := range []string{domain.Gauge, domain.Counter, domain.Histogram, domain.Summary, domain.Set} {
+		response := h.metricService.GetAllMetrics(&domain.GetAllMetricsRequest{
+			MetricType: metricType,
+			Matchers:   matchers,
+		})
+		if response.Error != nil {
+			log.Printf("failed to get metrics for metricType %s: %v", metricType, response.Error)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		for _, series := range response.Series {
+			family := prometheusName(metricType, series.Name)
+			if familyType, found := familyTypes[family]; found && familyType != metricType {
+				log.Printf("skipping metric %s of metricType %s: name %s is already exposed", series.Name, metricType, family)
+				continue
+			}
+			sample := family + prometheusLabels(series.Labels)
+			if written[sample] {
+				log.Printf("skipping metric %s of metricType %s: series %s is already exposed", series.Name, metricType, sample)
+				continue
+			}
+			written[sample] = true
+			if _, found := samples[family]; !found {
+				families = append(families, family)
+				familyTypes[family] = metricType
+				samples[family] = &bytes.Buffer{}
+			}
+			if series.Histogram != nil {
+				writeHistogram(samples[family], family, series.Labels, series.Histogram)
+				continue
+			}
+			if series.Summary != nil {
+				writeSummary(samples[family], family, series.Labels, series.Summary)
+				continue
+			}
+			fmt.Fprintf(samples[family], "%s %s\n", sample, series.Value)
+		}
+	}
+	var buf bytes.Buffer
+	for _, family := range families {
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", family, prometheusType(familyTypes[family]))
+		buf.Write(samples[family].Bytes())
+	}
+	w.Header().Set("Content-Type", prometheusContentType)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return
+	}
+}
+
+// writeHistogram writes the cumulative _bucket series of a histogram,
+// followed by its _sum and _count.
+func writeHistogram(w io.Writer, family string, labels domain.Labels, histogram *domain.HistogramValue) {
+	bucketLabels := maps.Clone(labels)
+	if bucketLabels == nil {
+		bucketLabels = make(domain.Labels, 1)
+	}
+	for _, bucket := range histogram.Buckets {
+		bucketLabels["le"] = strconv.FormatFloat(bucket.UpperBound, 'f', -1, 64)
+		fmt.Fprintf(w, "%s_bucket%s %d\n", family, prometheusLabels(bucketLabels), bucket.Count)
+	}
+	bucketLabels["le"] = "+Inf"
+	fmt.Fprintf(w, "%s_bucket%s %d\n", family, prometheusLabels(bucketLabels), histogram.Count)
+	fmt.Fprintf(w, "%s_sum%s %s\n", family, prometheusLabels(labels), strconv.FormatFloat(histogram.Sum, 'f', -1, 64))
+	fmt.Fprintf(w, "%s_count%s %d\n", family, prometheusLabels(labels), histogram.Count)
+}
+
+// writeSummary writes the quantile series of a summary, followed by its _sum
+// and _count.
+func writeSummary(w io.Writer, family string, labels domain.Labels, summary *domain.SummaryValue) {
+	quantileLabels := maps.Clone(labels)
+	if quantileLabels == nil {
+		quantileLabels = make(domain.Labels, 1)
+	}
+	for _, quantile := range summary.Quantiles {
+		quantileLabels["quantile"] = strconv.FormatFloat(quantile.Quantile, 'f', -1, 64)
+		fmt.Fprintf(w, "%s%s %s\n", family, prometheusLabels(quantileLabels),
+			strconv.FormatFloat(quantile.Value, 'f', -1, 64))
+	}
+	fmt.Fprintf(w, "%s_sum%s %s\n", family, prometheusLabels(labels), strconv.FormatFloat(summary.Sum, 'f', -1, 64))
+	fmt.Fprintf(w, "%s_count%s %d\n", family, prometheusLabels(labels), summary.Count)
+}
+
+type prometheusImportResponse struct {
+	Stored  int             `json:"stored"`
+	Skipped int             `json:"skipped"`
+	Errors  []promLineError `json:"errors,omitempty"`
+}
+
+// ImportPrometheusMetrics accepts a Prometheus text or OpenMetrics body the
+// way a pushgateway does. Nothing is stored if any line fails to parse, and
+// the offending lines are reported back. Counter samples are cumulative
+// totals, only their increase since the previous push is added.
+func (h *handler) ImportPrometheusMetrics(w http.ResponseWriter, req *http.Request) {
+	result, err := parsePrometheusText(req.Body)
+	if err != nil {
+		log.Printf("failed to read prometheus exposition: %v", err)
+		http.Error(w, "failed to read body", bodyErrorStatus(err))
+		return
+	}
+	response := &prometheusImportResponse{
+		Skipped: result.skipped,
+		Errors:  result.errors,
+	}
+	if len(result.errors) > 0 {
+		writeJSONStatus(w, http.StatusBadRequest, response)
+		return
+	}
+	request := &domain.SetMetricsRequest{
+		Metrics: make([]*domain.SetMetricRequest, 0, len(result.samples)),
+	}
+	err = h.promCounters.update(func(tx *totalsTx) error {
+		for _, sample := range result.samples {
+			value := sample.value
+			if sample.metricType == domain.Counter {
+				total, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return fmt.Errorf("%w: %w", domain.ErrIncorrectMetricValue, err)
+				}
+				_, delta := tx.advance(domain.Counter, sample.name, sample.labels, total, true)
+				value = strconv.FormatInt(delta, 10)
+			}
+			request.Metrics = append(request.Metrics, &domain.SetMetricRequest{
+				MetricType:  sample.metricType,
+				MetricName:  sample.name,
+				MetricValue: value,
+				Labels:      sample.labels,
+			})
+		}
+		return h.metricService.SetMetricValues(request).Error
+	})
+	if err != nil {
+		log.Printf("failed to set batch of %d metrics: %v", len(request.Metrics), err)
+		if errors.Is(err, domain.ErrIncorrectMetricValue) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+		return
+	}
+	response.Stored = len(request.Metrics)
+	writeJSON(w, response)
+}
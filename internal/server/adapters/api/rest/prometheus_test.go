@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func TestSanitizeMetricName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "valid", in: "HeapAlloc", want: "HeapAlloc"},
+		{name: "dots", in: "http.requests", want: "http_requests"},
+		{name: "leadingDigit", in: "5xx", want: "_5xx"},
+		{name: "colons", in: "job:rate5m", want: "job:rate5m"},
+		{name: "unicode", in: "запросы", want: "_______"},
+		{name: "empty", in: "", want: "_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sanitizeMetricName(tt.in))
+		})
+	}
+}
+
+func TestHandler_GetPrometheusMetrics(t *testing.T) {
+	gaugeStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	counterStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	metricService := service.NewMetricService(gaugeStorage, counterStorage)
+	for _, metric := range []*domain.SetMetricRequest{
+		{MetricType: domain.Gauge, MetricName: "heap.alloc", MetricValue: "1.5"},
+		{MetricType: domain.Gauge, MetricName: "heap_alloc", MetricValue: "2.5"},
+		{MetricType: domain.Gauge, MetricName: "Alloc", MetricValue: "100"},
+		{MetricType: domain.Counter, MetricName: "PollCount", MetricValue: "5"},
+		{MetricType: domain.Counter, MetricName: "requests_total", MetricValue: "7"},
+		{
+			MetricType:  domain.Counter,
+			MetricName:  "requests_total",
+			MetricValue: "3",
+			Labels:      domain.Labels{"service.name": "api", "path": `/a"b`},
+		},
+	} {
+		require.NoError(t, metricService.SetMetricValue(metric).Error)
+	}
+	h := handler{
+		metricService: metricService,
+	}
+	tests := []struct {
+		name       string
+		url        string
+		statusCode int
+		body       string
+	}{
+		{
+			name:       "all",
+			url:        "/metrics",
+			statusCode: http.StatusOK,
+			body: `# TYPE Alloc gauge
+Alloc 100
+# TYPE heap_alloc gauge
+heap_alloc 1.5
+# TYPE PollCount_total counter
+PollCount_total 5
+# TYPE requests_total counter
+requests_total 7
+requests_total{path="/a\"b",service_name="api"} 3
+`,
+		},
+		{
+			name:       "matchers",
+			url:        `/metrics?match=service.name="api"`,
+			statusCode: http.StatusOK,
+			body: `# TYPE requests_total counter
+requests_total{path="/a\"b",service_name="api"} 3
+`,
+		},
+		{
+			name:       "badMatcher",
+			url:        "/metrics?match=host",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.GetPrometheusMetrics(w, httptest.NewRequest(http.MethodGet, tt.url, http.NoBody))
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			assert.Equal(t, prometheusContentType, result.Header.Get("Content-Type"))
+			assert.Equal(t, tt.body, string(body))
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package rest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+var (
+	errInvalidSample    = errors.New("invalid sample")
+	errInvalidLabels    = errors.New("invalid labels")
+	errInvalidTypeLine  = errors.New("invalid TYPE line")
+	errContentAfterEOF  = errors.New("content after # EOF")
+	errNonIntegerCount  = errors.New("counter value is not an integer")
+	errNegativeCount    = errors.New("counter value is negative")
+	errCountOutOfRange  = errors.New("counter value is out of range")
+	errNonFiniteGauge   = errors.New("gauge value is not finite")
+	errDuplicateTypeDef = errors.New("duplicate TYPE line")
+)
+
+type promSample struct {
+	metricType string
+	name       string
+	labels     domain.Labels
+	value      string
+}
+
+type promLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type promParseResult struct {
+	samples []promSample
+	skipped int
+	errors  []promLineError
+}
+
+// parsePrometheusText reads the Prometheus text exposition format and its
+// OpenMetrics variant. Samples of gauge and counter families are returned,
+// other families are counted as skipped.
+func parsePrometheusText(r io.Reader) (*promParseResult, error) {
+	result := &promParseResult{}
+	types := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	eof := false
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if eof {
+			result.errors = append(result.errors, promLineError{Line: lineNumber, Error: errContentAfterEOF.Error()})
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			var err error
+			eof, err = parsePromComment(line, types)
+			if err != nil {
+				result.errors = append(result.errors, promLineError{Line: lineNumber, Error: err.Error()})
+			}
+			continue
+		}
+		sample, ok, err := parsePromSample(line, types)
+		switch {
+		case err != nil:
+			result.errors = append(result.errors, promLineError{Line: lineNumber, Error: err.Error()})
+		case !ok:
+			result.skipped++
+		default:
+			result.samples = append(result.samples, sample)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read exposition: %w", err)
+	}
+	return result, nil
+}
+
+// parsePromComment records TYPE lines and reports whether the line is the
+// OpenMetrics terminator. HELP, UNIT and free-form comments are ignored.
+func parsePromComment(line string, types map[string]string) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 2 && fields[1] == "EOF" {
+		return true, nil
+	}
+	if len(fields) < 2 || fields[1] != "TYPE" {
+		return false, nil
+	}
+	const typeLineFields = 4
+	if len(fields) != typeLineFields {
+		return false, errInvalidTypeLine
+	}
+	if _, found := types[fields[2]]; found {
+		return false, fmt.Errorf("%w for %s", errDuplicateTypeDef, fields[2])
+	}
+	types[fields[2]] = strings.ToLower(fields[3])
+	return false, nil
+}
+
+func parsePromSample(line string, types map[string]string) (promSample, bool, error) {
+	// OpenMetrics exemplars follow the value after " # ".
+	if i := strings.Index(line, " # "); i >= 0 {
+		line = line[:i]
+	}
+	name, rest := line, ""
+	var labels domain.Labels
+	if i := strings.IndexAny(line, "{ \t"); i >= 0 {
+		name, rest = line[:i], line[i:]
+	}
+	if name == "" {
+		return promSample{}, false, fmt.Errorf("%w: missing metric name", errInvalidSample)
+	}
+	if strings.HasPrefix(rest, "{") {
+		end := labelsEnd(rest)
+		if end < 0 {
+			return promSample{}, false, fmt.Errorf("%w: unterminated label set", errInvalidLabels)
+		}
+		var err error
+		if labels, err = parsePromLabels(rest[1:end]); err != nil {
+			return promSample{}, false, err
+		}
+		rest = rest[end+1:]
+	}
+	fields := strings.Fields(rest)
+	const maxSampleFields = 2
+	if len(fields) == 0 || len(fields) > maxSampleFields {
+		return promSample{}, false, fmt.Errorf("%w: expected value and optional timestamp", errInvalidSample)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return promSample{}, false, fmt.Errorf("%w: bad value %q", errInvalidSample, fields[0])
+	}
+	family, metricType := promFamily(name, types)
+	switch metricType {
+	case domain.Gauge:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return promSample{}, false, fmt.Errorf("%w: %q", errNonFiniteGauge, fields[0])
+		}
+		return promSample{
+			metricType: domain.Gauge,
+			name:       name,
+			labels:     labels,
+			value:      strconv.FormatFloat(value, 'f', -1, 64),
+		}, true, nil
+	case domain.Counter:
+		// OpenMetrics exposes the creation time of a counter as a separate
+		// sample; it is not a value.
+		if name == family+"_created" {
+			return promSample{}, false, nil
+		}
+		if value != math.Trunc(value) || math.IsInf(value, 0) {
+			return promSample{}, false, fmt.Errorf("%w: %q", errNonIntegerCount, fields[0])
+		}
+		if value < 0 {
+			return promSample{}, false, fmt.Errorf("%w: %q", errNegativeCount, fields[0])
+		}
+		if value >= math.MaxInt64 {
+			return promSample{}, false, fmt.Errorf("%w: %q", errCountOutOfRange, fields[0])
+		}
+		return promSample{
+			metricType: domain.Counter,
+			name:       name,
+			labels:     labels,
+			value:      strconv.FormatInt(int64(value), 10),
+		}, true, nil
+	default:
+		return promSample{}, false, nil
+	}
+}
+
+// promFamily finds the TYPE declared for a sample. OpenMetrics counters are
+// declared without the _total suffix their samples carry.
+func promFamily(name string, types map[string]string) (string, string) {
+	if metricType, found := types[name]; found {
+		return name, metricType
+	}
+	for _, suffix := range []string{"_total", "_created"} {
+		family := strings.TrimSuffix(name, suffix)
+		if family == name {
+			continue
+		}
+		if metricType, found := types[family]; found {
+			return family, metricType
+		}
+	}
+	return name, ""
+}
+
+func labelsEnd(s string) int {
+	inQuotes := false
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && inQuotes:
+			i++
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == '}' && !inQuotes:
+			return i
+		}
+	}
+	return -1
+}
+
+func parsePromLabels(s string) (domain.Labels, error) {
+	labels := make(domain.Labels)
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return labels, nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("%w: expected name=\"value\"", errInvalidLabels)
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		if !strings.HasPrefix(s, `"`) {
+			return nil, fmt.Errorf("%w: value of %s is not quoted", errInvalidLabels, key)
+		}
+		var value strings.Builder
+		i := 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			value.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return nil, fmt.Errorf("%w: unterminated value of %s", errInvalidLabels, key)
+		}
+		labels[key] = value.String()
+		s = strings.TrimLeft(s[i+1:], " \t")
+		if strings.HasPrefix(s, ",") {
+			s = s[1:]
+		} else if s != "" {
+			return nil, fmt.Errorf("%w: expected ',' after %s", errInvalidLabels, key)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func TestParsePrometheusText(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		samples []promSample
+		skipped int
+		errors  []int
+	}{
+		{
+			name: "prometheusText",
+			body: `# HELP queue_size Items in the queue.
+# TYPE queue_size gauge
+queue_size 12.5
+# TYPE jobs_processed_total counter
+jobs_processed_total{queue="fast",host="a"} 42 1712345678000
+# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{le="0.1"} 3
+untyped_metric 1
+`,
+			samples: []promSample{
+				{metricType: domain.Gauge, name: "queue_size", value: "12.5"},
+				{
+					metricType: domain.Counter,
+					name:       "jobs_processed_total",
+					labels:     domain.Labels{"host": "a", "queue": "fast"},
+					value:      "42",
+				},
+			},
+			skipped: 2,
+		},
+		{
+			name: "openMetrics",
+			body: `# TYPE jobs counter
+# UNIT jobs jobs
+jobs_total 7 # {trace_id="abc"} 1.0
+jobs_created 1712345678.5
+# TYPE temperature gauge
+temperature{room="a \"big\" one"} -3
+# EOF
+`,
+			samples: []promSample{
+				{metricType: domain.Counter, name: "jobs_total", value: "7"},
+				{
+					metricType: domain.Gauge,
+					name:       "temperature",
+					labels:     domain.Labels{"room": `a "big" one`},
+					value:      "-3",
+				},
+			},
+			skipped: 1,
+		},
+		{
+			name: "lineErrors",
+			body: `# TYPE a gauge
+a not-a-number
+# TYPE b counter
+b 1.5
+c{x="1" 3
+# TYPE d
+# EOF
+e 1
+`,
+			errors: []int{2, 4, 5, 6, 8},
+		},
+		{
+			name: "valueErrors",
+			body: `# TYPE g gauge
+g NaN
+g{x="1"} +Inf
+# TYPE c counter
+c -3
+c{x="1"} 1e30
+c{x="2"} 5
+`,
+			samples: []promSample{
+				{metricType: domain.Counter, name: "c", labels: domain.Labels{"x": "2"}, value: "5"},
+			},
+			errors: []int{2, 3, 5, 6},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parsePrometheusText(strings.NewReader(tt.body))
+			require.NoError(t, err)
+			assert.Equal(t, tt.samples, result.samples)
+			assert.Equal(t, tt.skipped, result.skipped)
+			lines := make([]int, 0, len(result.errors))
+			for _, lineErr := range result.errors {
+				lines = append(lines, lineErr.Line)
+			}
+			if len(tt.errors) == 0 {
+				assert.Empty(t, lines)
+			} else {
+				assert.Equal(t, tt.errors, lines)
+			}
+		})
+	}
+}
+
+func TestHandler_ImportPrometheusMetrics(t *testing.T) {
+	tests := []struct {
+		name       string
+		bodies     []string
+		statusCode int
+		stored     int
+		counter    string
+	}{
+		{
+			name:       "stored",
+			bodies:     []string{"# TYPE jobs_total counter\njobs_total 3\n# TYPE queue gauge\nqueue 1\n"},
+			statusCode: http.StatusOK,
+			stored:     2,
+			counter:    "5",
+		},
+		{
+			name: "sameTotalPushedTwice",
+			bodies: []string{
+				"# TYPE jobs_total counter\njobs_total 3\n",
+				"# TYPE jobs_total counter\njobs_total 3\n",
+			},
+			statusCode: http.StatusOK,
+			stored:     1,
+			counter:    "5",
+		},
+		{
+			name: "totalIncreased",
+			bodies: []string{
+				"# TYPE jobs_total counter\njobs_total 3\n",
+				"# TYPE jobs_total counter\njobs_total 7\n",
+			},
+			statusCode: http.StatusOK,
+			stored:     1,
+			counter:    "9",
+		},
+		{
+			name:       "rejectedOnParseError",
+			bodies:     []string{"# TYPE jobs_total counter\njobs_total 3\nqueue{ 1\n"},
+			statusCode: http.StatusBadRequest,
+			counter:    "2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gaugeStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			counterStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			metricService := service.NewMetricService(gaugeStorage, counterStorage)
+			metricService.SetMetricValue(&domain.SetMetricRequest{
+				MetricType:  domain.Counter,
+				MetricName:  "jobs_total",
+				MetricValue: "2",
+			})
+			h := handler{
+				metricService: metricService,
+				promCounters:  newRunningTotals(metricService),
+			}
+			var w *httptest.ResponseRecorder
+			for _, body := range tt.bodies {
+				w = httptest.NewRecorder()
+				h.ImportPrometheusMetrics(w, httptest.NewRequest(http.MethodPost, "/metrics", bytes.NewBufferString(body)))
+			}
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			var response prometheusImportResponse
+			require.NoError(t, json.NewDecoder(result.Body).Decode(&response))
+			assert.Equal(t, tt.stored, response.Stored)
+			counter := metricService.GetMetricValue(&domain.MetricRequest{
+				MetricType: domain.Counter,
+				MetricName: "jobs_total",
+			})
+			assert.Equal(t, tt.counter, counter.MetricValue)
+		})
+	}
+}
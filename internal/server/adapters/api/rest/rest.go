@@ -1,10 +1,18 @@
 package rest
 
 import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -15,11 +23,18 @@ import (
 type MetricService interface {
 	GetMetricValue(request *domain.MetricRequest) *domain.MetricResponse
 	SetMetricValue(request *domain.SetMetricRequest) *domain.SetMetricResponse
+	SetMetricValues(request *domain.SetMetricsRequest) *domain.SetMetricResponse
 	GetAllMetrics(request *domain.GetAllMetricsRequest) *domain.GetAllMetricsResponse
+	DeleteMetric(request *domain.MetricRequest) *domain.DeleteMetricResponse
+	ResetMetric(request *domain.MetricRequest) *domain.ResetMetricResponse
+	GetMetricHistory(request *domain.MetricHistoryRequest) *domain.MetricHistoryResponse
 }
 
 type handler struct {
 	metricService MetricService
+	alertService  AlertService
+	otlpSums      *runningTotals
+	promCounters  *runningTotals
 }
 
 type API struct {
@@ -34,16 +49,66 @@ func (a *API) Run() error {
 	return nil
 }
 
-func NewAPI(metricService MetricService, cfg *Config) *API {
+func NewAPI(metricService MetricService, cfg *Config, opts ...Option) (*API, error) {
 	h := &handler{
 		metricService: metricService,
+		otlpSums:      newRunningTotals(metricService),
+		promCounters:  newRunningTotals(metricService),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	var privateKey *rsa.PrivateKey
+	if cfg.CryptoKey != "" {
+		var err error
+		if privateKey, err = loadPrivateKey(cfg.CryptoKey); err != nil {
+			return nil, fmt.Errorf("failed to load crypto key: %w", err)
+		}
+	}
+	var trustedSubnet *net.IPNet
+	if cfg.TrustedSubnet != "" {
+		var err error
+		if _, trustedSubnet, err = net.ParseCIDR(cfg.TrustedSubnet); err != nil {
+			return nil, fmt.Errorf("failed to parse trusted subnet: %w", err)
+		}
 	}
 	r := chi.NewRouter()
-	r.Route("/update", func(r chi.Router) {
-		r.Post("/{metricType}/{metricName}/{metricValue}", h.SetMetricValue)
+	r.Use(loggingMiddleware(newLogger(cfg, os.Stdout)))
+	r.Use(limitBodyMiddleware)
+	// Only the agent encrypts and signs its payloads. Other clients such as
+	// Telegraf, OpenTelemetry SDKs or batch jobs post plain bodies. Deleting
+	// and resetting series is destructive, so it needs a signature as well.
+	r.Group(func(r chi.Router) {
+		r.Use(subnetMiddleware(trustedSubnet))
+		r.Use(decryptMiddleware(privateKey))
+		r.Use(gzipMiddleware)
+		r.Use(hashMiddleware(cfg.Key))
+		r.Route("/update", func(r chi.Router) {
+			r.Post("/", h.SetMetricValueJSON)
+			r.Post("/{metricType}/{metricName}/{metricValue}", h.SetMetricValue)
+		})
+		r.Post("/updates/", h.SetMetricValuesJSON)
+		r.Delete("/value/{metricType}/{metricName}", h.DeleteMetric)
+		r.Post("/reset/counter/{metricName}", h.ResetCounter)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(subnetMiddleware(trustedSubnet))
+		r.Use(gzipMiddleware)
+		r.Post("/metrics", h.ImportPrometheusMetrics)
+		r.Post("/write", h.WriteInfluxMetrics)
+		r.Post("/v1/metrics", h.ReceiveOTLPMetrics)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(gzipMiddleware)
+		r.Post("/value/", h.GetMetricValueJSON)
+		r.Get("/value/{metricType}/{metricName}", h.GetMetricValue)
+		r.Get("/query_range", h.QueryRange)
+		if h.alertService != nil {
+			r.Get("/alerts", h.GetAlerts)
+		}
+		r.Get("/metrics", h.GetPrometheusMetrics)
+		r.Get("/", h.GetAllMetrics)
 	})
-	r.Get("/value/{metricType}/{metricName}", h.GetMetricValue)
-	r.Get("/", h.GetAllMetrics)
 	return &API{
 		srv: &http.Server{
 			Addr:         cfg.Address,
@@ -51,7 +116,7 @@ func NewAPI(metricService MetricService, cfg *Config) *API {
 			ReadTimeout:  time.Second,
 			WriteTimeout: time.Second,
 		},
-	}
+	}, nil
 }
 
 func (h *handler) SetMetricValue(w http.ResponseWriter, req *http.Request) {
@@ -62,6 +127,7 @@ func (h *handler) SetMetricValue(w http.ResponseWriter, req *http.Request) {
 		MetricType:  metricType,
 		MetricName:  metricName,
 		MetricValue: metricValue,
+		Labels:      labelsFromQuery(req),
 	})
 	if response.Error != nil {
 		log.Printf(
@@ -82,12 +148,122 @@ func (h *handler) SetMetricValue(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+func (h *handler) SetMetricValueJSON(w http.ResponseWriter, req *http.Request) {
+	var metric domain.Metrics
+	if err := json.NewDecoder(req.Body).Decode(&metric); err != nil {
+		log.Printf("failed to decode metric: %v", err)
+		http.Error(w, "incorrect json body", bodyErrorStatus(err))
+		return
+	}
+	metricValue, err := formatMetricValue(&metric)
+	if err == nil {
+		err = h.metricService.SetMetricValue(&domain.SetMetricRequest{
+			MetricType:  metric.MType,
+			MetricName:  metric.ID,
+			MetricValue: metricValue,
+			Sketch:      metric.Sketch,
+			Labels:      metric.Labels,
+		}).Error
+	}
+	if err != nil {
+		log.Printf("failed to set metric value for metricType %s, metricName %s: %v", metric.MType, metric.ID, err)
+		switch {
+		case errors.Is(err, domain.ErrIncorrectMetricType):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrIncorrectMetricValue):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+		return
+	}
+	stored, err := h.getStoredMetric(metric.MType, metric.ID, metric.Labels)
+	if err != nil {
+		log.Printf("failed to get stored metric %s of metricType %s: %v", metric.ID, metric.MType, err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, stored)
+}
+
+func (h *handler) SetMetricValuesJSON(w http.ResponseWriter, req *http.Request) {
+	var metrics []domain.Metrics
+	if err := json.NewDecoder(req.Body).Decode(&metrics); err != nil {
+		log.Printf("failed to decode metrics: %v", err)
+		http.Error(w, "incorrect json body", bodyErrorStatus(err))
+		return
+	}
+	request := &domain.SetMetricsRequest{
+		Metrics: make([]*domain.SetMetricRequest, 0, len(metrics)),
+	}
+	var err error
+	for i := range metrics {
+		var metricValue string
+		if metricValue, err = formatMetricValue(&metrics[i]); err != nil {
+			break
+		}
+		request.Metrics = append(request.Metrics, &domain.SetMetricRequest{
+			MetricType:  metrics[i].MType,
+			MetricName:  metrics[i].ID,
+			MetricValue: metricValue,
+			Sketch:      metrics[i].Sketch,
+			Labels:      metrics[i].Labels,
+		})
+	}
+	if err == nil {
+		err = h.metricService.SetMetricValues(request).Error
+	}
+	if err != nil {
+		log.Printf("failed to set batch of %d metrics: %v", len(metrics), err)
+		switch {
+		case errors.Is(err, domain.ErrIncorrectMetricType):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrIncorrectMetricValue):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+		return
+	}
+	stored := make([]*domain.Metrics, 0, len(request.Metrics))
+	seen := make(map[string]bool, len(request.Metrics))
+	for _, metric := range request.Metrics {
+		key := metric.MetricType + " " + domain.SeriesID(metric.MetricName, metric.Labels)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		value, err := h.getStoredMetric(metric.MetricType, metric.MetricName, metric.Labels)
+		if err != nil {
+			log.Printf("failed to get stored metric %s of metricType %s: %v", metric.MetricName, metric.MetricType, err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		stored = append(stored, value)
+	}
+	writeJSON(w, stored)
+}
+
+// GetMetricValue writes the value of a series as plain text. For a summary
+// the q query parameter selects a quantile instead of the count. A summary
+// without observations has no quantiles and is reported as not found.
 func (h *handler) GetMetricValue(w http.ResponseWriter, req *http.Request) {
 	metricType, metricName := chi.URLParam(req, "metricType"), chi.URLParam(req, "metricName")
-	response := h.metricService.GetMetricValue(&domain.MetricRequest{
+	request := &domain.MetricRequest{
 		MetricType: metricType,
 		MetricName: metricName,
-	})
+		Labels:     labelsFromQuery(req),
+	}
+	if req.URL.Query().Has(quantileParam) {
+		quantile, err := parseQuantile(metricType, req.URL.Query().Get(quantileParam))
+		if err != nil {
+			log.Printf("failed to parse quantile of metric %s: %v", metricName, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		request.Quantiles = []float64{quantile}
+	}
+	response := h.metricService.GetMetricValue(request)
 	if !response.Found {
 		http.Error(w, domain.ErrItemNotFound.Error(), http.StatusNotFound)
 		return
@@ -106,30 +282,172 @@ func (h *handler) GetMetricValue(w http.ResponseWriter, req *http.Request) {
 		}
 		return
 	}
-	if _, err := w.Write([]byte(response.MetricValue)); err != nil {
+	value := response.MetricValue
+	if len(request.Quantiles) > 0 {
+		if response.Summary == nil || len(response.Summary.Quantiles) == 0 {
+			http.Error(w, errNoObservations.Error(), http.StatusNotFound)
+			return
+		}
+		value = strconv.FormatFloat(response.Summary.Quantiles[0].Value, 'f', -1, 64)
+	}
+	if _, err := w.Write([]byte(value)); err != nil {
 		return
 	}
 }
 
-func (h *handler) GetAllMetrics(w http.ResponseWriter, req *http.Request) {
-	gauge := h.metricService.GetAllMetrics(&domain.GetAllMetricsRequest{MetricType: domain.Gauge})
-	if gauge.Error != nil {
-		log.Printf("failed to get an item: %v for metricType %s", gauge.Error, domain.Gauge)
+var (
+	errInvalidQuantile = errors.New("invalid quantile")
+	errNoObservations  = errors.New("summary has no observations")
+)
+
+func parseQuantile(metricType, value string) (float64, error) {
+	if metricType != domain.Summary {
+		return 0, fmt.Errorf("%w: only summaries have quantiles", errInvalidQuantile)
+	}
+	quantile, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", errInvalidQuantile, err)
+	}
+	if !(quantile >= 0 && quantile <= 1) {
+		return 0, fmt.Errorf("%w: %v is not between 0 and 1", errInvalidQuantile, quantile)
+	}
+	return quantile, nil
+}
+
+func (h *handler) GetMetricValueJSON(w http.ResponseWriter, req *http.Request) {
+	var metric domain.Metrics
+	if err := json.NewDecoder(req.Body).Decode(&metric); err != nil {
+		log.Printf("failed to decode metric: %v", err)
+		http.Error(w, "incorrect json body", bodyErrorStatus(err))
+		return
+	}
+	response := h.metricService.GetMetricValue(&domain.MetricRequest{
+		MetricType: metric.MType,
+		MetricName: metric.ID,
+		Labels:     metric.Labels,
+	})
+	if response.Error != nil {
+		log.Printf(
+			"failed to get metric value for metricType %s, metricName %s: %v",
+			metric.MType,
+			metric.ID,
+			response.Error,
+		)
+		if errors.Is(response.Error, domain.ErrIncorrectMetricType) {
+			http.Error(w, domain.ErrItemNotFound.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+		return
+	}
+	if !response.Found {
 		http.Error(w, domain.ErrItemNotFound.Error(), http.StatusNotFound)
 		return
 	}
-	counter := h.metricService.GetAllMetrics(&domain.GetAllMetricsRequest{MetricType: domain.Counter})
-	if counter.Error != nil {
-		log.Printf("failed to get an item: %v for metricType %s", gauge.Error, domain.Gauge)
+	stored, err := parseMetricValue(metric.MType, metric.ID, metric.Labels, response)
+	if err != nil {
+		log.Printf("failed to parse stored metric %s of metricType %s: %v", metric.ID, metric.MType, err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, stored)
+}
+
+func (h *handler) DeleteMetric(w http.ResponseWriter, req *http.Request) {
+	metricType, metricName := chi.URLParam(req, "metricType"), chi.URLParam(req, "metricName")
+	response := h.metricService.DeleteMetric(&domain.MetricRequest{
+		MetricType: metricType,
+		MetricName: metricName,
+		Labels:     labelsFromQuery(req),
+	})
+	if response.Error != nil {
+		log.Printf(
+			"failed to delete metric for metricType %s, metricName %s: %v",
+			metricType,
+			metricName,
+			response.Error,
+		)
+		if errors.Is(response.Error, domain.ErrIncorrectMetricType) {
+			http.Error(w, domain.ErrItemNotFound.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+		return
+	}
+	if !response.Found {
 		http.Error(w, domain.ErrItemNotFound.Error(), http.StatusNotFound)
 		return
 	}
-	html := "<html><body><ul>"
-	for key, value := range gauge.Values {
-		html += fmt.Sprintf("<li>%s: %v</li>", key, value)
+	h.forgetTotals(metricType, metricName, labelsFromQuery(req))
+}
+
+// forgetTotals drops the pushed totals of a deleted or reset series, so the
+// next push doesn't count from a baseline the store no longer has.
+func (h *handler) forgetTotals(metricType, metricName string, labels domain.Labels) {
+	series := domain.SeriesID(metricName, labels)
+	for _, totals := range []*runningTotals{h.otlpSums, h.promCounters} {
+		if totals != nil {
+			totals.forget(metricType, series)
+		}
 	}
-	for key, value := range counter.Values {
-		html += fmt.Sprintf("<li>%s: %v</li>", key, value)
+}
+
+func (h *handler) ResetCounter(w http.ResponseWriter, req *http.Request) {
+	metricName := chi.URLParam(req, "metricName")
+	response := h.metricService.ResetMetric(&domain.MetricRequest{
+		MetricType: domain.Counter,
+		MetricName: metricName,
+		Labels:     labelsFromQuery(req),
+	})
+	if response.Error != nil {
+		log.Printf("failed to reset counter %s: %v", metricName, response.Error)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	if !response.Found {
+		http.Error(w, domain.ErrItemNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	h.forgetTotals(domain.Counter, metricName, labelsFromQuery(req))
+}
+
+func (h *handler) GetAllMetrics(w http.ResponseWriter, req *http.Request) {
+	matchers, err := matchersFromQuery(req)
+	if err != nil {
+		log.Printf("failed to parse label matchers: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var all []*domain.Series
+	for _, metricType := range []string{domain.Gauge, domain.Counter, domain.Histogram, domain.Summary, domain.Set} {
+		response := h.metricService.GetAllMetrics(&domain.GetAllMetricsRequest{MetricType: metricType, Matchers: matchers})
+		if response.Error != nil {
+			log.Printf("failed to get an item: %v for metricType %s", response.Error, metricType)
+			http.Error(w, domain.ErrItemNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		all = append(all, response.Series...)
+	}
+	html := "<html><body><ul>"
+	for _, series := range all {
+		id := template.HTMLEscapeString(domain.SeriesID(series.Name, series.Labels))
+		value := series.Value
+		if series.Histogram != nil {
+			value = formatHistogram(series.Histogram)
+		}
+		if series.Summary != nil {
+			value = formatSummary(series.Summary)
+		}
+		if series.Stale {
+			html += fmt.Sprintf(
+				"<li>%s: %v (stale, last updated %s)</li>",
+				id,
+				value,
+				series.UpdatedAt.UTC().Format(time.RFC3339),
+			)
+			continue
+		}
+		html += fmt.Sprintf("<li>%s: %v</li>", id, value)
 	}
 	html += "</ul></body></html>"
 	w.Header().Set("Content-Type", "text/html")
@@ -137,3 +455,138 @@ func (h *handler) GetAllMetrics(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 }
+
+// formatHistogram shows a histogram as its count, sum and cumulative buckets.
+func formatHistogram(histogram *domain.HistogramValue) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "count=%d sum=%s", histogram.Count, strconv.FormatFloat(histogram.Sum, 'f', -1, 64))
+	for _, bucket := range histogram.Buckets {
+		fmt.Fprintf(&b, " le%s=%d", strconv.FormatFloat(bucket.UpperBound, 'f', -1, 64), bucket.Count)
+	}
+	fmt.Fprintf(&b, " le+Inf=%d", histogram.Count)
+	return b.String()
+}
+
+// formatSummary shows a summary as its count, sum and quantiles.
+func formatSummary(summary *domain.SummaryValue) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "count=%d sum=%s", summary.Count, strconv.FormatFloat(summary.Sum, 'f', -1, 64))
+	for _, quantile := range summary.Quantiles {
+		fmt.Fprintf(&b, " q%s=%s",
+			strconv.FormatFloat(quantile.Quantile, 'f', -1, 64),
+			strconv.FormatFloat(quantile.Value, 'f', -1, 64),
+		)
+	}
+	return b.String()
+}
+
+func (h *handler) getStoredMetric(metricType, metricName string, labels domain.Labels) (*domain.Metrics, error) {
+	response := h.metricService.GetMetricValue(&domain.MetricRequest{
+		MetricType: metricType,
+		MetricName: metricName,
+		Labels:     labels,
+	})
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	if !response.Found {
+		return nil, domain.ErrItemNotFound
+	}
+	return parseMetricValue(metricType, metricName, labels, response)
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	writeJSONStatus(w, http.StatusOK, v)
+}
+
+// writeJSONStatus encodes v before sending the status, so a value that can't
+// be encoded turns into a 500 instead of an empty success.
+func writeJSONStatus(w http.ResponseWriter, statusCode int, v any) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		log.Printf("failed to encode response: %v", err)
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
+
+func formatMetricValue(metric *domain.Metrics) (string, error) {
+	switch metric.MType {
+	case domain.Gauge, domain.Histogram, domain.Summary:
+		if metric.Value == nil {
+			return "", domain.ErrIncorrectMetricValue
+		}
+		return strconv.FormatFloat(*metric.Value, 'f', -1, 64), nil
+	case domain.Counter:
+		if metric.Delta == nil {
+			return "", domain.ErrIncorrectMetricValue
+		}
+		return strconv.FormatInt(*metric.Delta, 10), nil
+	case domain.Set:
+		switch {
+		case metric.Member != nil:
+			return *metric.Member, nil
+		case metric.Sketch != nil:
+			return "", nil
+		default:
+			return "", domain.ErrIncorrectMetricValue
+		}
+	default:
+		return "", domain.ErrIncorrectMetricType
+	}
+}
+
+func parseMetricValue(
+	metricType, metricName string,
+	labels domain.Labels,
+	response *domain.MetricResponse,
+) (*domain.Metrics, error) {
+	metric := &domain.Metrics{
+		ID:     metricName,
+		MType:  metricType,
+		Labels: labels,
+	}
+	metricValue := response.MetricValue
+	switch metricType {
+	case domain.Gauge:
+		value, err := strconv.ParseFloat(metricValue, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", domain.ErrIncorrectMetricValue, err)
+		}
+		metric.Value = &value
+	case domain.Counter:
+		delta, err := strconv.ParseInt(metricValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", domain.ErrIncorrectMetricValue, err)
+		}
+		metric.Delta = &delta
+	case domain.Histogram:
+		if response.Histogram == nil {
+			return nil, domain.ErrIncorrectMetricValue
+		}
+		metric.Histogram = response.Histogram
+	case domain.Summary:
+		if response.Summary == nil {
+			return nil, domain.ErrIncorrectMetricValue
+		}
+		metric.Summary = response.Summary
+	case domain.Set:
+		cardinality, err := strconv.ParseUint(metricValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", domain.ErrIncorrectMetricValue, err)
+		}
+		metric.Cardinality = &cardinality
+	default:
+		return nil, domain.ErrIncorrectMetricType
+	}
+	return metric, nil
+}
@@ -3,13 +3,16 @@ package rest
 import (
 	"bytes"
 	"context"
+	"io"
 	"log"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
 	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
@@ -144,6 +147,20 @@ func TestHandler_SetMetricValueFailed(t *testing.T) {
 				statusCode:  http.StatusBadRequest,
 			},
 		},
+		{
+			name: "statusInfiniteGauge",
+			url:  "/update/{metricType}/{metricName}/{metricValue}",
+			metric: Metric{
+				Name:  "someMetric",
+				Value: "Inf",
+				Type:  domain.Gauge,
+			},
+			method: http.MethodPost,
+			want: want{
+				contentType: "text/plain",
+				statusCode:  http.StatusBadRequest,
+			},
+		},
 		{
 			name: "statusIncorrectMetricValue",
 			url:  "/update/{metricType}/{metricName}/{metricValue}",
@@ -188,3 +205,446 @@ func TestHandler_SetMetricValueFailed(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteJSONStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      any
+		statusCode int
+		body       string
+	}{
+		{
+			name:       "encoded",
+			value:      map[string]float64{"value": 1.5},
+			statusCode: http.StatusCreated,
+			body:       `{"value":1.5}`,
+		},
+		{
+			name:       "unsupportedValue",
+			value:      map[string]float64{"value": math.Inf(1)},
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeJSONStatus(w, http.StatusCreated, tt.value)
+			assert.Equal(t, tt.statusCode, w.Code)
+			if tt.body != "" {
+				assert.JSONEq(t, tt.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_SetMetricValueJSON(t *testing.T) {
+	type want struct {
+		contentType string
+		statusCode  int
+		body        string
+	}
+	tests := []struct {
+		name string
+		body string
+		want want
+	}{
+		{
+			name: "statusOkGauge",
+			body: `{"id":"someMetric","type":"gauge","value":13.5}`,
+			want: want{
+				contentType: "application/json",
+				statusCode:  http.StatusOK,
+				body:        `{"id":"someMetric","type":"gauge","value":13.5}`,
+			},
+		},
+		{
+			name: "statusOkCounter",
+			body: `{"id":"someMetric","type":"counter","delta":13}`,
+			want: want{
+				contentType: "application/json",
+				statusCode:  http.StatusOK,
+				body:        `{"id":"someMetric","type":"counter","delta":13}`,
+			},
+		},
+		{
+			name: "statusIncorrectMetricType",
+			body: `{"id":"someMetric","type":"unknown","value":13.5}`,
+			want: want{
+				contentType: "text/plain; charset=utf-8",
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			name: "statusMissingCounterDelta",
+			body: `{"id":"someMetric","type":"counter","value":13.5}`,
+			want: want{
+				contentType: "text/plain; charset=utf-8",
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			name: "statusIncorrectJSON",
+			body: `{"id":"someMetric",`,
+			want: want{
+				contentType: "text/plain; charset=utf-8",
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/update/", bytes.NewBufferString(tt.body))
+			gaugeStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			counterStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			metricService := service.NewMetricService(gaugeStorage, counterStorage)
+			h := handler{
+				metricService: metricService,
+			}
+			h.SetMetricValueJSON(w, r)
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.want.statusCode, result.StatusCode)
+			assert.Equal(t, tt.want.contentType, result.Header.Get("Content-Type"))
+			if tt.want.body != "" {
+				body, err := io.ReadAll(result.Body)
+				assert.NoError(t, err)
+				assert.JSONEq(t, tt.want.body, string(body))
+			}
+		})
+	}
+}
+
+func TestHandler_GetMetricValueJSON(t *testing.T) {
+	type want struct {
+		statusCode int
+		body       string
+	}
+	tests := []struct {
+		name string
+		body string
+		want want
+	}{
+		{
+			name: "statusOkGauge",
+			body: `{"id":"gaugeMetric","type":"gauge"}`,
+			want: want{
+				statusCode: http.StatusOK,
+				body:       `{"id":"gaugeMetric","type":"gauge","value":1.25}`,
+			},
+		},
+		{
+			name: "statusOkCounter",
+			body: `{"id":"counterMetric","type":"counter"}`,
+			want: want{
+				statusCode: http.StatusOK,
+				body:       `{"id":"counterMetric","type":"counter","delta":7}`,
+			},
+		},
+		{
+			name: "statusNotFound",
+			body: `{"id":"unknownMetric","type":"gauge"}`,
+			want: want{
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name: "statusIncorrectMetricType",
+			body: `{"id":"gaugeMetric","type":"unknown"}`,
+			want: want{
+				statusCode: http.StatusNotFound,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/value/", bytes.NewBufferString(tt.body))
+			gaugeStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			counterStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			metricService := service.NewMetricService(gaugeStorage, counterStorage)
+			metricService.SetMetricValue(&domain.SetMetricRequest{
+				MetricType:  domain.Gauge,
+				MetricName:  "gaugeMetric",
+				MetricValue: "1.25",
+			})
+			metricService.SetMetricValue(&domain.SetMetricRequest{
+				MetricType:  domain.Counter,
+				MetricName:  "counterMetric",
+				MetricValue: "7",
+			})
+			h := handler{
+				metricService: metricService,
+			}
+			h.GetMetricValueJSON(w, r)
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.want.statusCode, result.StatusCode)
+			if tt.want.body != "" {
+				body, err := io.ReadAll(result.Body)
+				assert.NoError(t, err)
+				assert.JSONEq(t, tt.want.body, string(body))
+			}
+		})
+	}
+}
+
+func TestHandler_SetMetricValuesJSON(t *testing.T) {
+	type want struct {
+		statusCode int
+		body       string
+		gauge      string
+		counter    string
+	}
+	tests := []struct {
+		name string
+		body string
+		want want
+	}{
+		{
+			name: "statusOkBatch",
+			body: `[
+				{"id":"gaugeMetric","type":"gauge","value":2.5},
+				{"id":"counterMetric","type":"counter","delta":3},
+				{"id":"counterMetric","type":"counter","delta":4}
+			]`,
+			want: want{
+				statusCode: http.StatusOK,
+				body: `[
+					{"id":"gaugeMetric","type":"gauge","value":2.5},
+					{"id":"counterMetric","type":"counter","delta":8}
+				]`,
+				gauge:   "2.5",
+				counter: "8",
+			},
+		},
+		{
+			name: "statusIncorrectMetricValueRejectsBatch",
+			body: `[
+				{"id":"gaugeMetric","type":"gauge","value":2.5},
+				{"id":"counterMetric","type":"counter","delta":3},
+				{"id":"counterMetric","type":"counter"}
+			]`,
+			want: want{
+				statusCode: http.StatusBadRequest,
+				gauge:      "1.25",
+				counter:    "1",
+			},
+		},
+		{
+			name: "statusIncorrectMetricTypeRejectsBatch",
+			body: `[
+				{"id":"gaugeMetric","type":"gauge","value":2.5},
+				{"id":"otherMetric","type":"unknown","value":3}
+			]`,
+			want: want{
+				statusCode: http.StatusBadRequest,
+				gauge:      "1.25",
+				counter:    "1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(tt.body))
+			gaugeStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			counterStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			metricService := service.NewMetricService(gaugeStorage, counterStorage)
+			metricService.SetMetricValue(&domain.SetMetricRequest{
+				MetricType:  domain.Gauge,
+				MetricName:  "gaugeMetric",
+				MetricValue: "1.25",
+			})
+			metricService.SetMetricValue(&domain.SetMetricRequest{
+				MetricType:  domain.Counter,
+				MetricName:  "counterMetric",
+				MetricValue: "1",
+			})
+			h := handler{
+				metricService: metricService,
+			}
+			h.SetMetricValuesJSON(w, r)
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.want.statusCode, result.StatusCode)
+			if tt.want.body != "" {
+				body, err := io.ReadAll(result.Body)
+				assert.NoError(t, err)
+				assert.JSONEq(t, tt.want.body, string(body))
+			}
+			gauge := metricService.GetMetricValue(&domain.MetricRequest{
+				MetricType: domain.Gauge,
+				MetricName: "gaugeMetric",
+			})
+			assert.Equal(t, tt.want.gauge, gauge.MetricValue)
+			counter := metricService.GetMetricValue(&domain.MetricRequest{
+				MetricType: domain.Counter,
+				MetricName: "counterMetric",
+			})
+			assert.Equal(t, tt.want.counter, counter.MetricValue)
+		})
+	}
+}
+
+func newTestStorage(t *testing.T, cfg *memory.Config) storage.MetricStorage {
+	t.Helper()
+	metricStorage, err := storage.NewStorage(storage.Config{
+		Memory: cfg,
+	})
+	require.NoError(t, err)
+	return metricStorage
+}
+
+// newTestMetricService returns a service holding gaugeMetric and
+// counterMetric. Options add the storages of the other metric types.
+func newTestMetricService(t *testing.T, opts ...service.Option) *service.MetricService {
+	t.Helper()
+	metricService := service.NewMetricService(
+		newTestStorage(t, &memory.Config{}),
+		newTestStorage(t, &memory.Config{}),
+		opts...,
+	)
+	metricService.SetMetricValue(&domain.SetMetricRequest{
+		MetricType:  domain.Gauge,
+		MetricName:  "gaugeMetric",
+		MetricValue: "1.25",
+	})
+	metricService.SetMetricValue(&domain.SetMetricRequest{
+		MetricType:  domain.Counter,
+		MetricName:  "counterMetric",
+		MetricValue: "5",
+	})
+	return metricService
+}
+
+func TestHandler_DeleteMetric(t *testing.T) {
+	tests := []struct {
+		name       string
+		metricType string
+		metricName string
+		statusCode int
+	}{
+		{
+			name:       "statusOkGauge",
+			metricType: domain.Gauge,
+			metricName: "gaugeMetric",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "statusOkCounter",
+			metricType: domain.Counter,
+			metricName: "counterMetric",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "statusNotFoundUnknownMetric",
+			metricType: domain.Gauge,
+			metricName: "counterMetric",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "statusNotFoundUnknownType",
+			metricType: "unknown",
+			metricName: "gaugeMetric",
+			statusCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodDelete, "/value/{metricType}/{metricName}", http.NoBody)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("metricType", tt.metricType)
+			rctx.URLParams.Add("metricName", tt.metricName)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			metricService := newTestMetricService(t)
+			h := handler{
+				metricService: metricService,
+			}
+			h.DeleteMetric(w, r)
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			if tt.statusCode == http.StatusOK {
+				response := metricService.GetMetricValue(&domain.MetricRequest{
+					MetricType: tt.metricType,
+					MetricName: tt.metricName,
+				})
+				assert.False(t, response.Found)
+			}
+		})
+	}
+}
+
+func TestHandler_ResetCounter(t *testing.T) {
+	tests := []struct {
+		name       string
+		metricName string
+		statusCode int
+	}{
+		{
+			name:       "statusOk",
+			metricName: "counterMetric",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "statusNotFound",
+			metricName: "gaugeMetric",
+			statusCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/reset/counter/{metricName}", http.NoBody)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("metricName", tt.metricName)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			metricService := newTestMetricService(t)
+			h := handler{
+				metricService: metricService,
+			}
+			h.ResetCounter(w, r)
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			counter := metricService.GetMetricValue(&domain.MetricRequest{
+				MetricType: domain.Counter,
+				MetricName: "counterMetric",
+			})
+			if tt.statusCode == http.StatusOK {
+				assert.Equal(t, "0", counter.MetricValue)
+			} else {
+				assert.Equal(t, "5", counter.MetricValue)
+			}
+		})
+	}
+}
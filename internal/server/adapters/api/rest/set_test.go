@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func TestHandler_Set(t *testing.T) {
+	h := handler{
+		metricService: newTestMetricService(t, service.WithSetStorage(newTestStorage(t, &memory.Config{}))),
+	}
+	for _, member := range []string{"alice", "bob", "alice"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/update/set/users/"+member, http.NoBody)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("metricType", domain.Set)
+		rctx.URLParams.Add("metricName", "users")
+		rctx.URLParams.Add("metricValue", member)
+		h.SetMetricValue(w, r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx)))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// Another agent counted bob and carol on its own.
+	sketch, err := domain.NewHyperLogLog(domain.HLLPrecision)
+	require.NoError(t, err)
+	sketch.Add("bob")
+	sketch.Add("carol")
+	data, err := sketch.MarshalBinary()
+	require.NoError(t, err)
+	small, err := domain.NewHyperLogLog(domain.HLLPrecision - 1)
+	require.NoError(t, err)
+	smallData, err := small.MarshalBinary()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		body       string
+		statusCode int
+		want       string
+	}{
+		{
+			name:       "member",
+			body:       `{"id":"users","type":"set","member":"dave"}`,
+			statusCode: http.StatusOK,
+			want:       `{"id":"users","type":"set","cardinality":3}`,
+		},
+		{
+			name:       "sketch",
+			body:       `{"id":"users","type":"set","sketch":"` + base64.StdEncoding.EncodeToString(data) + `"}`,
+			statusCode: http.StatusOK,
+			want:       `{"id":"users","type":"set","cardinality":4}`,
+		},
+		{
+			name:       "otherPrecision",
+			body:       `{"id":"users","type":"set","sketch":"` + base64.StdEncoding.EncodeToString(smallData) + `"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "badSketch",
+			body:       `{"id":"users","type":"set","sketch":"AAAA"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "noMember",
+			body:       `{"id":"users","type":"set"}`,
+			statusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.SetMetricValueJSON(w, httptest.NewRequest(http.MethodPost, "/update/", bytes.NewBufferString(tt.body)))
+			assert.Equal(t, tt.statusCode, w.Code)
+			if tt.want != "" {
+				assert.JSONEq(t, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	h.GetPrometheusMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics?match=__name__=users", http.NoBody))
+	assert.Equal(t, "# TYPE users gauge\nusers 4\n", w.Body.String())
+}
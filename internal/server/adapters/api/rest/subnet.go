@@ -0,0 +1,29 @@
+package rest
+
+import (
+	"log"
+	"net"
+	"net/http"
+)
+
+const realIPHeader = "X-Real-IP"
+
+// subnetMiddleware only lets requests through when their X-Real-IP belongs to
+// the trusted subnet. It guards the routes that write metrics, lookups and
+// other reads are not restricted.
+func subnetMiddleware(subnet *net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if subnet == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ip := net.ParseIP(req.Header.Get(realIPHeader))
+			if ip == nil || !subnet.Contains(ip) {
+				log.Printf("rejected %s %s from untrusted address %q", req.Method, req.RequestURI, req.Header.Get(realIPHeader))
+				http.Error(w, "untrusted address", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
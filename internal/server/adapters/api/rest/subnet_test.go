@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func TestSubnetMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		realIP     string
+		statusCode int
+	}{
+		{
+			name:       "trustedAddress",
+			method:     http.MethodPost,
+			url:        "/update/gauge/someMetric/13.5",
+			realIP:     "192.168.1.15",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "untrustedAddress",
+			method:     http.MethodPost,
+			url:        "/update/gauge/someMetric/13.5",
+			realIP:     "10.0.0.1",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "missingAddress",
+			method:     http.MethodPost,
+			url:        "/update/gauge/someMetric/13.5",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "untrustedImport",
+			method:     http.MethodPost,
+			url:        "/write",
+			body:       "cpu value=0.5",
+			realIP:     "10.0.0.1",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "untrustedDelete",
+			method:     http.MethodDelete,
+			url:        "/value/gauge/someMetric",
+			realIP:     "10.0.0.1",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "readOnlyFromAnywhere",
+			method:     http.MethodGet,
+			url:        "/",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "lookupFromAnywhere",
+			method:     http.MethodPost,
+			url:        "/value/",
+			body:       `{"id":"someMetric","type":"gauge"}`,
+			statusCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gaugeStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			counterStorage, _ := storage.NewStorage(storage.Config{
+				Memory: &memory.Config{},
+			})
+			metricService := service.NewMetricService(gaugeStorage, counterStorage)
+			api, err := NewAPI(metricService, &Config{TrustedSubnet: "192.168.1.0/24"})
+			require.NoError(t, err)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+			if tt.realIP != "" {
+				r.Header.Set(realIPHeader, tt.realIP)
+			}
+			api.srv.Handler.ServeHTTP(w, r)
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+		})
+	}
+}
+
+func TestNewAPIInvalidTrustedSubnet(t *testing.T) {
+	_, err := NewAPI(nil, &Config{TrustedSubnet: "192.168.1.0"})
+	assert.Error(t, err)
+}
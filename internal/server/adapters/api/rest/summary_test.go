@@ -0,0 +1,142 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func TestHandler_GetSummaryQuantile(t *testing.T) {
+	metricService := newTestMetricService(t, service.WithSummaryStorage(newTestStorage(t, &memory.Config{})))
+	request := &domain.SetMetricsRequest{}
+	for i := 1; i <= 100; i++ {
+		request.Metrics = append(request.Metrics, &domain.SetMetricRequest{
+			MetricType:  domain.Summary,
+			MetricName:  "latency",
+			MetricValue: strconv.Itoa(i),
+		})
+	}
+	require.NoError(t, metricService.SetMetricValues(request).Error)
+	h := handler{
+		metricService: metricService,
+	}
+	tests := []struct {
+		name       string
+		metricType string
+		query      string
+		statusCode int
+		want       string
+	}{
+		{name: "count", metricType: domain.Summary, statusCode: http.StatusOK, want: "100"},
+		{name: "max", metricType: domain.Summary, query: "?q=1", statusCode: http.StatusOK, want: "100"},
+		{name: "median", metricType: domain.Summary, query: "?q=0.5", statusCode: http.StatusOK, want: "50.5"},
+		{name: "outOfRange", metricType: domain.Summary, query: "?q=1.5", statusCode: http.StatusBadRequest},
+		{name: "notANumber", metricType: domain.Summary, query: "?q=p99", statusCode: http.StatusBadRequest},
+		{name: "notASummary", metricType: domain.Gauge, query: "?q=0.5", statusCode: http.StatusBadRequest},
+		{name: "otherSeries", metricType: domain.Summary, query: "?q=0.5&host=a", statusCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/value/"+tt.metricType+"/latency"+tt.query, http.NoBody)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("metricType", tt.metricType)
+			rctx.URLParams.Add("metricName", "latency")
+			h.GetMetricValue(w, r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx)))
+			result := w.Result()
+			defer func() {
+				err := result.Body.Close()
+				log.Print("error occurred body close: %w", err)
+			}()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			if tt.want != "" {
+				body, err := io.ReadAll(result.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, string(body))
+			}
+		})
+	}
+}
+
+// emptySummaryService finds every series as a summary without observations.
+type emptySummaryService struct {
+	MetricService
+}
+
+func (emptySummaryService) GetMetricValue(*domain.MetricRequest) *domain.MetricResponse {
+	return &domain.MetricResponse{Found: true, MetricValue: "0", Summary: &domain.SummaryValue{}}
+}
+
+func TestHandler_GetEmptySummaryQuantile(t *testing.T) {
+	h := handler{
+		metricService: emptySummaryService{},
+	}
+	tests := []struct {
+		name       string
+		query      string
+		statusCode int
+		want       string
+	}{
+		{name: "count", statusCode: http.StatusOK, want: "0"},
+		{name: "quantile", query: "?q=0.9", statusCode: http.StatusNotFound, want: "summary has no observations\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/value/summary/latency"+tt.query, http.NoBody)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("metricType", domain.Summary)
+			rctx.URLParams.Add("metricName", "latency")
+			h.GetMetricValue(w, r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx)))
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.Equal(t, tt.want, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_Summary(t *testing.T) {
+	h := handler{
+		metricService: newTestMetricService(t, service.WithSummaryStorage(newTestStorage(t, &memory.Config{}))),
+	}
+	w := httptest.NewRecorder()
+	h.SetMetricValueJSON(w, httptest.NewRequest(http.MethodPost, "/update/",
+		bytes.NewBufferString(`{"id":"rtt","type":"summary","value":0.25}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":"rtt","type":"summary","summary":{"quantiles":[`+
+		`{"quantile":0.5,"value":0.25},{"quantile":0.9,"value":0.25},{"quantile":0.99,"value":0.25}],`+
+		`"sum":0.25,"count":1}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.GetAllMetrics(w, httptest.NewRequest(http.MethodGet, "/?match=__name__=rtt", http.NoBody))
+	assert.Equal(t, `<html><body><ul><li>rtt: count=1 sum=0.25 q0.5=0.25 q0.9=0.25 q0.99=0.25</li>`+
+		`</ul></body></html>`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.GetPrometheusMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics?match=__name__=rtt", http.NoBody))
+	assert.Equal(t, "# TYPE rtt summary\n"+
+		`rtt{quantile="0.5"} 0.25`+"\n"+
+		`rtt{quantile="0.9"} 0.25`+"\n"+
+		`rtt{quantile="0.99"} 0.25`+"\n"+
+		"rtt_sum 0.25\n"+
+		"rtt_count 1\n", w.Body.String())
+
+	response := h.metricService.SetMetricValue(&domain.SetMetricRequest{
+		MetricType:  domain.Summary,
+		MetricName:  "rtt",
+		MetricValue: "-Inf",
+	})
+	assert.ErrorIs(t, response.Error, domain.ErrIncorrectMetricValue)
+}
@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+// totalsPruneInterval is how often baselines of series that are gone from
+// the store, for instance after expiring, are dropped.
+const totalsPruneInterval = time.Minute
+
+// runningTotal is the last total pushed for a series.
+type runningTotal struct {
+	metricType string
+	name       string
+	labels     domain.Labels
+	total      float64
+}
+
+// runningTotals remembers the last total pushed for every counter series, by
+// OTLP sums or Prometheus imports. Counters in this server only ever receive
+// integer deltas, so each push is turned into the difference between the
+// truncated new and previous totals. That works for both temporalities and
+// never loses the fractional part of float sums. A baseline lives as long as
+// its series: it is dropped once the series is deleted, reset or expired.
+type runningTotals struct {
+	mux           *sync.Mutex
+	metricService MetricService
+	totals        map[string]*runningTotal
+	prunedAt      time.Time
+}
+
+func newRunningTotals(metricService MetricService) *runningTotals {
+	return &runningTotals{
+		mux:           &sync.Mutex{},
+		metricService: metricService,
+		totals:        make(map[string]*runningTotal),
+		prunedAt:      time.Now(),
+	}
+}
+
+// totalsTx collects the totals of one push. They only replace the remembered
+// ones once the push has been stored.
+type totalsTx struct {
+	totals  *runningTotals
+	pending map[string]*runningTotal
+}
+
+// update runs fn with the totals locked and keeps the totals it advanced
+// only when fn succeeds, so a rejected push leaves no trace.
+func (s *runningTotals) update(fn func(tx *totalsTx) error) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if now := time.Now(); now.Sub(s.prunedAt) >= totalsPruneInterval {
+		s.prunedAt = now
+		for series, baseline := range s.totals {
+			if !s.stored(baseline) {
+				delete(s.totals, series)
+			}
+		}
+	}
+	tx := &totalsTx{
+		totals:  s,
+		pending: make(map[string]*runningTotal),
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	for series, baseline := range tx.pending {
+		s.totals[series] = baseline
+	}
+	return nil
+}
+
+// forget drops the baseline of a series of the given type, the next push
+// counts from zero.
+func (s *runningTotals) forget(metricType, series string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if baseline, found := s.totals[series]; found && baseline.metricType == metricType {
+		delete(s.totals, series)
+	}
+}
+
+func (s *runningTotals) stored(baseline *runningTotal) bool {
+	response := s.metricService.GetMetricValue(&domain.MetricRequest{
+		MetricType: baseline.metricType,
+		MetricName: baseline.name,
+		Labels:     baseline.labels,
+	})
+	return response.Error == nil && response.Found
+}
+
+// advance records a data point and returns the new running total together
+// with the integer delta since the previous one. A cumulative value lower
+// than the last one means the producer restarted and counts from zero.
+func (tx *totalsTx) advance(
+	metricType, name string,
+	labels domain.Labels,
+	value float64,
+	cumulative bool,
+) (float64, int64) {
+	series := domain.SeriesID(name, labels)
+	var previous float64
+	if baseline, found := tx.pending[series]; found {
+		previous = baseline.total
+	} else if baseline, found = tx.totals.totals[series]; found && tx.totals.stored(baseline) {
+		previous = baseline.total
+	}
+	total := previous + value
+	if cumulative {
+		total = value
+		if value < previous {
+			previous = 0
+		}
+	}
+	tx.pending[series] = &runningTotal{
+		metricType: metricType,
+		name:       name,
+		labels:     labels,
+		total:      total,
+	}
+	return total, int64(math.Trunc(total) - math.Trunc(previous))
+}
@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+var errTestRejected = errors.New("rejected")
+
+// pushTotal advances a counter series and stores the delta the way the OTLP
+// and Prometheus handlers do. A failing push is rolled back.
+func pushTotal(t *testing.T, totals *runningTotals, name string, value float64, fail bool) int64 {
+	t.Helper()
+	var delta int64
+	err := totals.update(func(tx *totalsTx) error {
+		_, delta = tx.advance(domain.Counter, name, nil, value, true)
+		if fail {
+			return errTestRejected
+		}
+		return totals.metricService.SetMetricValue(&domain.SetMetricRequest{
+			MetricType:  domain.Counter,
+			MetricName:  name,
+			MetricValue: strconv.FormatInt(delta, 10),
+		}).Error
+	})
+	if !fail {
+		require.NoError(t, err)
+	}
+	return delta
+}
+
+func TestRunningTotals_Advance(t *testing.T) {
+	totals := newRunningTotals(newTestMetricService(t))
+	assert.Equal(t, int64(5), pushTotal(t, totals, "c", 5.5, false))
+	assert.Equal(t, int64(2), pushTotal(t, totals, "c", 7.25, false))
+	assert.Equal(t, int64(1), pushTotal(t, totals, "c", 1, false), "a lower cumulative value is a restart")
+
+	err := totals.update(func(tx *totalsTx) error {
+		total, delta := tx.advance(domain.Gauge, "d", nil, 0.6, false)
+		assert.Equal(t, int64(0), delta)
+		assert.InDelta(t, 0.6, total, 1e-9)
+		_, delta = tx.advance(domain.Gauge, "d", nil, 0.6, false)
+		assert.Equal(t, int64(1), delta, "points of one push build on each other")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestRunningTotals_Rollback(t *testing.T) {
+	totals := newRunningTotals(newTestMetricService(t))
+	pushTotal(t, totals, "c", 5, false)
+	pushTotal(t, totals, "c", 100, true)
+	assert.Equal(t, int64(2), pushTotal(t, totals, "c", 7, false))
+}
+
+func TestRunningTotals_Eviction(t *testing.T) {
+	metricService := newTestMetricService(t)
+	totals := newRunningTotals(metricService)
+
+	pushTotal(t, totals, "deleted", 5, false)
+	metricService.DeleteMetric(&domain.MetricRequest{MetricType: domain.Counter, MetricName: "deleted"})
+	assert.Equal(t, int64(7), pushTotal(t, totals, "deleted", 7, false), "a deleted series starts over")
+
+	pushTotal(t, totals, "reset", 5, false)
+	totals.forget(domain.Gauge, "reset")
+	assert.Equal(t, int64(2), pushTotal(t, totals, "reset", 7, false), "only the given type is forgotten")
+	totals.forget(domain.Counter, "reset")
+	assert.Equal(t, int64(9), pushTotal(t, totals, "reset", 9, false))
+
+	pushTotal(t, totals, "expired", 5, false)
+	metricService.DeleteMetric(&domain.MetricRequest{MetricType: domain.Counter, MetricName: "expired"})
+	totals.prunedAt = time.Now().Add(-totalsPruneInterval)
+	pushTotal(t, totals, "other", 1, false)
+	assert.NotContains(t, totals.totals, "expired")
+	assert.Contains(t, totals.totals, "reset")
+}
@@ -0,0 +1,8 @@
+package statsd
+
+import "time"
+
+type Config struct {
+	Address       string
+	FlushInterval time.Duration
+}
@@ -0,0 +1,77 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	typeCounter = "c"
+	typeGauge   = "g"
+	typeTimer   = "ms"
+)
+
+var errInvalidLine = errors.New("invalid statsd line")
+
+type sample struct {
+	name       string
+	metricType string
+	value      float64
+	// relative is set for gauges sent as +N or -N, which adjust the current
+	// value instead of replacing it.
+	relative   bool
+	sampleRate float64
+}
+
+// parseLine reads a single name:value|type[|@rate] statsd line.
+func parseLine(line string) (*sample, error) {
+	name, rest, found := strings.Cut(line, ":")
+	if !found || name == "" {
+		return nil, fmt.Errorf("%w %q: missing name", errInvalidLine, line)
+	}
+	parts := strings.Split(rest, "|")
+	const minParts, maxParts = 2, 3
+	if len(parts) < minParts || len(parts) > maxParts {
+		return nil, fmt.Errorf("%w %q: expected value|type[|@rate]", errInvalidLine, line)
+	}
+	s := &sample{
+		name:       name,
+		metricType: parts[1],
+		sampleRate: 1,
+	}
+	switch s.metricType {
+	case typeCounter, typeGauge, typeTimer:
+	default:
+		return nil, fmt.Errorf("%w %q: unsupported type %q", errInvalidLine, line, s.metricType)
+	}
+	value, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: bad value: %w", errInvalidLine, line, err)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, fmt.Errorf("%w %q: value must be finite", errInvalidLine, line)
+	}
+	s.value = value
+	s.relative = s.metricType == typeGauge && (strings.HasPrefix(parts[0], "+") || strings.HasPrefix(parts[0], "-"))
+	if len(parts) == maxParts {
+		rate, found := strings.CutPrefix(parts[2], "@")
+		if !found {
+			return nil, fmt.Errorf("%w %q: bad sample rate", errInvalidLine, line)
+		}
+		if s.sampleRate, err = strconv.ParseFloat(rate, 64); err != nil || s.sampleRate <= 0 || s.sampleRate > 1 {
+			return nil, fmt.Errorf("%w %q: sample rate must be in (0, 1]", errInvalidLine, line)
+		}
+	}
+	if s.metricType == typeCounter && !fitsInt64(s.value/s.sampleRate) {
+		return nil, fmt.Errorf("%w %q: counter out of range", errInvalidLine, line)
+	}
+	return s, nil
+}
+
+// fitsInt64 reports whether a counter value can be stored as an int64 delta.
+func fitsInt64(value float64) bool {
+	return value >= math.MinInt64 && value < math.MaxInt64
+}
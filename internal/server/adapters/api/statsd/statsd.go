@@ -0,0 +1,243 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+const (
+	maxPacketSize = 65535
+	timerQuantile = 0.9
+)
+
+type MetricService interface {
+	GetMetricValue(request *domain.MetricRequest) *domain.MetricResponse
+	SetMetricValue(request *domain.SetMetricRequest) *domain.SetMetricResponse
+	SetMetricValues(request *domain.SetMetricsRequest) *domain.SetMetricResponse
+}
+
+// Listener accepts statsd packets over UDP and aggregates them until the next
+// flush. Counters are flushed as deltas, gauges as their last value and
+// timers as count, min, max, mean and p90 gauges named <timer>.<stat>.
+type Listener struct {
+	metricService MetricService
+	config        *Config
+	mux           *sync.Mutex
+	counters      map[string]float64
+	gauges        map[string]float64
+	timers        map[string]*timer
+}
+
+type timer struct {
+	values []float64
+	count  float64
+}
+
+func NewListener(metricService MetricService, cfg *Config) *Listener {
+	return &Listener{
+		metricService: metricService,
+		config:        cfg,
+		mux:           &sync.Mutex{},
+		counters:      make(map[string]float64),
+		gauges:        make(map[string]float64),
+		timers:        make(map[string]*timer),
+	}
+}
+
+func (l *Listener) Run() error {
+	if l.config.FlushInterval <= 0 {
+		return fmt.Errorf("flush interval must be positive, got %s", l.config.FlushInterval)
+	}
+	conn, err := net.ListenPacket("udp", l.config.Address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", l.config.Address, err)
+	}
+	return l.run(conn)
+}
+
+// run serves conn and flushes on every tick. Once serving stops, whatever is
+// still aggregated is flushed before returning.
+func (l *Listener) run(conn net.PacketConn) error {
+	ticker := time.NewTicker(l.config.FlushInterval)
+	defer ticker.Stop()
+	done := make(chan struct{})
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		for {
+			select {
+			case <-ticker.C:
+				l.flush()
+			case <-done:
+				l.flush()
+				return
+			}
+		}
+	}()
+	err := l.serve(conn)
+	close(done)
+	<-flushed
+	return err
+}
+
+func (l *Listener) serve(conn net.PacketConn) error {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to read statsd packet: %w", err)
+		}
+		l.handlePacket(string(buf[:n]))
+	}
+}
+
+func (l *Listener) handlePacket(packet string) {
+	for _, line := range strings.Split(packet, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		s, err := parseLine(line)
+		if err != nil {
+			log.Printf("failed to parse statsd line: %v", err)
+			continue
+		}
+		if err = l.add(s); err != nil {
+			log.Printf("failed to aggregate statsd line: %v", err)
+		}
+	}
+}
+
+func (l *Listener) add(s *sample) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	switch s.metricType {
+	case typeCounter:
+		total := l.counters[s.name] + s.value/s.sampleRate
+		if !fitsInt64(total) {
+			return fmt.Errorf("%w: counter %s out of range", errInvalidLine, s.name)
+		}
+		l.counters[s.name] = total
+	case typeGauge:
+		if !s.relative {
+			l.gauges[s.name] = s.value
+			return nil
+		}
+		current, found := l.gauges[s.name]
+		if !found {
+			current = l.storedGauge(s.name)
+		}
+		l.gauges[s.name] = current + s.value
+	case typeTimer:
+		t, found := l.timers[s.name]
+		if !found {
+			t = &timer{}
+			l.timers[s.name] = t
+		}
+		t.values = append(t.values, s.value)
+		t.count += 1 / s.sampleRate
+	}
+	return nil
+}
+
+func (l *Listener) storedGauge(name string) float64 {
+	response := l.metricService.GetMetricValue(&domain.MetricRequest{
+		MetricType: domain.Gauge,
+		MetricName: name,
+	})
+	if response.Error != nil || !response.Found {
+		return 0
+	}
+	value, err := strconv.ParseFloat(response.MetricValue, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// flush writes everything aggregated since the previous flush as one batch.
+// The fractional part of sampled counters is carried over to the next flush.
+// A batch is stored as a whole or not at all, so when it is rejected the
+// metrics are stored one by one and only the offending ones are dropped.
+func (l *Listener) flush() {
+	request := l.collect()
+	if len(request.Metrics) == 0 {
+		return
+	}
+	if response := l.metricService.SetMetricValues(request); response.Error == nil {
+		return
+	}
+	for _, metric := range request.Metrics {
+		if response := l.metricService.SetMetricValue(metric); response.Error != nil {
+			log.Printf("failed to flush statsd metric %s: %v", metric.MetricName, response.Error)
+		}
+	}
+}
+
+func (l *Listener) collect() *domain.SetMetricsRequest {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	request := &domain.SetMetricsRequest{}
+	for name, value := range l.counters {
+		delta := math.Trunc(value)
+		if remainder := value - delta; remainder != 0 {
+			l.counters[name] = remainder
+		} else {
+			delete(l.counters, name)
+		}
+		if delta == 0 {
+			continue
+		}
+		request.Metrics = append(request.Metrics, &domain.SetMetricRequest{
+			MetricType:  domain.Counter,
+			MetricName:  name,
+			MetricValue: strconv.FormatInt(int64(delta), 10),
+		})
+	}
+	for name, value := range l.gauges {
+		request.Metrics = append(request.Metrics, gauge(name, value))
+	}
+	for name, t := range l.timers {
+		request.Metrics = append(request.Metrics, t.stats(name)...)
+	}
+	l.gauges = make(map[string]float64)
+	l.timers = make(map[string]*timer)
+	return request
+}
+
+func (t *timer) stats(name string) []*domain.SetMetricRequest {
+	sort.Float64s(t.values)
+	sum := 0.0
+	for _, value := range t.values {
+		sum += value
+	}
+	rank := int(math.Ceil(timerQuantile*float64(len(t.values)))) - 1
+	return []*domain.SetMetricRequest{
+		gauge(name+".count", t.count),
+		gauge(name+".min", t.values[0]),
+		gauge(name+".max", t.values[len(t.values)-1]),
+		gauge(name+".mean", sum/float64(len(t.values))),
+		gauge(name+".p90", t.values[max(rank, 0)]),
+	}
+}
+
+func gauge(name string, value float64) *domain.SetMetricRequest {
+	return &domain.SetMetricRequest{
+		MetricType:  domain.Gauge,
+		MetricName:  name,
+		MetricValue: strconv.FormatFloat(value, 'f', -1, 64),
+	}
+}
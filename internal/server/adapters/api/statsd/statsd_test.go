@@ -0,0 +1,233 @@
+package statsd
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage"
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+	"github.com/agatma/sprint1-http-server/internal/server/core/service"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    *sample
+		wantErr bool
+	}{
+		{
+			name: "counter",
+			line: "requests:3|c",
+			want: &sample{name: "requests", metricType: typeCounter, value: 3, sampleRate: 1},
+		},
+		{
+			name: "sampledCounter",
+			line: "requests:1|c|@0.1",
+			want: &sample{name: "requests", metricType: typeCounter, value: 1, sampleRate: 0.1},
+		},
+		{
+			name: "gauge",
+			line: "queue.size:42.5|g",
+			want: &sample{name: "queue.size", metricType: typeGauge, value: 42.5, sampleRate: 1},
+		},
+		{
+			name: "relativeGauge",
+			line: "queue.size:-5|g",
+			want: &sample{name: "queue.size", metricType: typeGauge, value: -5, relative: true, sampleRate: 1},
+		},
+		{
+			name: "timer",
+			line: "db.query:320|ms",
+			want: &sample{name: "db.query", metricType: typeTimer, value: 320, sampleRate: 1},
+		},
+		{name: "missingName", line: ":1|c", wantErr: true},
+		{name: "missingType", line: "requests:1", wantErr: true},
+		{name: "unsupportedType", line: "users:1|s", wantErr: true},
+		{name: "badValue", line: "requests:x|c", wantErr: true},
+		{name: "notANumber", line: "requests:NaN|c", wantErr: true},
+		{name: "infinite", line: "requests:Inf|c", wantErr: true},
+		{name: "infiniteGauge", line: "queue.size:-Inf|g", wantErr: true},
+		{name: "counterOutOfRange", line: "requests:1e30|c", wantErr: true},
+		{name: "sampledCounterOutOfRange", line: "requests:1e18|c|@0.01", wantErr: true},
+		{name: "badRate", line: "requests:1|c|0.1", wantErr: true},
+		{name: "rateOutOfRange", line: "requests:1|c|@2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLine(tt.line)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestListener(t *testing.T) {
+	gaugeStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	counterStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	metricService := service.NewMetricService(gaugeStorage, counterStorage)
+	metricService.SetMetricValue(&domain.SetMetricRequest{
+		MetricType:  domain.Gauge,
+		MetricName:  "queue",
+		MetricValue: "10",
+	})
+	listener := NewListener(metricService, &Config{FlushInterval: time.Second})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		done <- listener.serve(conn)
+	}()
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		_ = client.Close()
+	}()
+	packets := []string{
+		"requests:3|c\nrequests:1|c|@0.5",
+		"queue:+5|g\nqueue:-2|g",
+		"db:10|ms\ndb:30|ms\ndb:20|ms",
+		"broken line\nrequests:1|c",
+	}
+	for _, packet := range packets {
+		_, err = client.Write([]byte(packet))
+		require.NoError(t, err)
+	}
+	assert.Eventually(t, func() bool {
+		listener.mux.Lock()
+		defer listener.mux.Unlock()
+		return listener.counters["requests"] == 6
+	}, time.Second, 10*time.Millisecond)
+	listener.flush()
+	require.NoError(t, conn.Close())
+	require.NoError(t, <-done)
+
+	expected := []struct {
+		metricType string
+		name       string
+		value      string
+	}{
+		{domain.Counter, "requests", "6"},
+		{domain.Gauge, "queue", "13"},
+		{domain.Gauge, "db.count", "3"},
+		{domain.Gauge, "db.min", "10"},
+		{domain.Gauge, "db.max", "30"},
+		{domain.Gauge, "db.mean", "20"},
+		{domain.Gauge, "db.p90", "30"},
+	}
+	for _, e := range expected {
+		response := metricService.GetMetricValue(&domain.MetricRequest{
+			MetricType: e.metricType,
+			MetricName: e.name,
+		})
+		assert.Equal(t, e.value, response.MetricValue, e.name)
+	}
+}
+
+func TestListener_CarriesCounterRemainder(t *testing.T) {
+	gaugeStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	counterStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	metricService := service.NewMetricService(gaugeStorage, counterStorage)
+	listener := NewListener(metricService, &Config{FlushInterval: time.Second})
+	listener.handlePacket("hits:1|c|@0.4")
+	listener.flush()
+	listener.handlePacket("hits:1|c|@0.4")
+	listener.flush()
+	response := metricService.GetMetricValue(&domain.MetricRequest{
+		MetricType: domain.Counter,
+		MetricName: "hits",
+	})
+	assert.Equal(t, "5", response.MetricValue)
+}
+
+func TestListener_FlushesOnExit(t *testing.T) {
+	gaugeStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	counterStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	metricService := service.NewMetricService(gaugeStorage, counterStorage)
+	listener := NewListener(metricService, &Config{FlushInterval: time.Hour})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		done <- listener.run(conn)
+	}()
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		_ = client.Close()
+	}()
+	_, err = client.Write([]byte("requests:3|c"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		listener.mux.Lock()
+		defer listener.mux.Unlock()
+		return listener.counters["requests"] == 3
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, conn.Close())
+	require.NoError(t, <-done)
+
+	response := metricService.GetMetricValue(&domain.MetricRequest{
+		MetricType: domain.Counter,
+		MetricName: "requests",
+	})
+	assert.Equal(t, "3", response.MetricValue)
+}
+
+func TestListener_FlushSkipsRejectedMetrics(t *testing.T) {
+	gaugeStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	counterStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	metricService := service.NewMetricService(gaugeStorage, counterStorage)
+	listener := NewListener(metricService, &Config{FlushInterval: time.Second})
+	listener.handlePacket("hits:2|c\nbad{name}:1|c")
+	listener.flush()
+	response := metricService.GetMetricValue(&domain.MetricRequest{
+		MetricType: domain.Counter,
+		MetricName: "hits",
+	})
+	assert.Equal(t, "2", response.MetricValue)
+}
+
+func TestListener_DropsCounterOverflow(t *testing.T) {
+	gaugeStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	counterStorage, _ := storage.NewStorage(storage.Config{
+		Memory: &memory.Config{},
+	})
+	metricService := service.NewMetricService(gaugeStorage, counterStorage)
+	listener := NewListener(metricService, &Config{FlushInterval: time.Second})
+	listener.handlePacket("hits:6e18|c\nhits:6e18|c")
+	listener.flush()
+	response := metricService.GetMetricValue(&domain.MetricRequest{
+		MetricType: domain.Counter,
+		MetricName: "hits",
+	})
+	assert.Equal(t, "6000000000000000000", response.MetricValue)
+}
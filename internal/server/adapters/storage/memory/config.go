@@ -1,3 +1,47 @@
 package memory
 
-type Config struct{}
+import "time"
+
+// DefaultRollups keeps a day of minutely and a month of hourly rollups.
+var DefaultRollups = []Rollup{
+	{Resolution: time.Minute, Retention: 24 * time.Hour},
+	{Resolution: time.Hour, Retention: 30 * 24 * time.Hour},
+}
+
+// DefaultBuckets are the histogram upper bounds of the Prometheus clients.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultQuantiles are the summary quantiles reported when a request doesn't
+// ask for specific ones.
+var DefaultQuantiles = []float64{0.5, 0.9, 0.99}
+
+// DefaultCompression bounds a summary to about a hundred centroids while keeping
+// tail quantiles within a fraction of a percent.
+const DefaultCompression = 100
+
+// Config sets how much history is kept per series. Samples older than
+// Retention are dropped, and so are the oldest ones beyond MaxSamples.
+// History is disabled when either is zero. Rollups lists coarser
+// resolutions, finest first, that old samples are aggregated into.
+//
+// Series not updated for StaleAfter are flagged as stale, and those not
+// updated for TTL are hidden until Expire evicts them. Zero disables either.
+//
+// Buckets are the upper bounds histograms count observations into, in
+// ascending order. DefaultBuckets are used when it's empty. Compression
+// trades the memory of a summary for the accuracy of its quantiles,
+// DefaultCompression is used when it's zero.
+type Config struct {
+	Retention   time.Duration
+	MaxSamples  int
+	Rollups     []Rollup
+	StaleAfter  time.Duration
+	TTL         time.Duration
+	Buckets     []float64
+	Compression float64
+}
+
+type Rollup struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+// histogram counts observations per bucket. counts has one more element
+// than the bucket bounds for the +Inf bucket.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+func (h *histogram) observe(bounds []float64, value float64) {
+	h.counts[sort.SearchFloat64s(bounds, value)]++
+	h.sum += value
+	h.count++
+}
+
+// snapshot returns the cumulative buckets of h, or nil for a series that
+// isn't a histogram.
+func (h *histogram) snapshot(bounds []float64) *domain.HistogramValue {
+	if h == nil {
+		return nil
+	}
+	value := &domain.HistogramValue{
+		Buckets: make([]domain.Bucket, len(bounds)),
+		Sum:     h.sum,
+		Count:   h.count,
+	}
+	var cumulative uint64
+	for i, bound := range bounds {
+		cumulative += h.counts[i]
+		value.Buckets[i] = domain.Bucket{
+			UpperBound: bound,
+			Count:      cumulative,
+		}
+	}
+	return value
+}
+
+func parseObservation(value string) (float64, error) {
+	observation, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err //nolint:wrapcheck // callers only check for a failure
+	}
+	if math.IsNaN(observation) || math.IsInf(observation, 0) {
+		return 0, domain.ErrIncorrectMetricValue
+	}
+	return observation, nil
+}
+
+// observe adds an observation to a histogram. The series value is the
+// observation count, so its history reads like a counter.
+func (s *MetricStorage) observe(req *domain.SetMetricRequest) *domain.SetMetricResponse {
+	observation, err := parseObservation(req.MetricValue)
+	if err != nil {
+		return &domain.SetMetricResponse{
+			Error: domain.ErrIncorrectMetricValue,
+		}
+	}
+	id := domain.SeriesID(req.MetricName, req.Labels)
+	state := newHistogram(s.buckets)
+	if stored, found := s.lookup(id); found && stored.histogram != nil {
+		state = stored.histogram
+	}
+	state.observe(s.buckets, observation)
+	s.set(req.MetricName, req.Labels, strconv.FormatUint(state.count, 10), 1)
+	s.data[id].histogram = state
+	return &domain.SetMetricResponse{
+		Error: nil,
+	}
+}
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+func TestMetricStorage_Histogram(t *testing.T) {
+	s := NewStorage(&Config{Buckets: []float64{0.1, 0.5, 1}})
+	for _, value := range []string{"0.05", "0.1", "0.3", "0.7", "3"} {
+		require.NoError(t, s.SetMetricValue(&domain.SetMetricRequest{
+			MetricType:  domain.Histogram,
+			MetricName:  "latency",
+			MetricValue: value,
+		}).Error)
+	}
+	response := s.GetMetricValue(&domain.MetricRequest{MetricName: "latency"})
+	require.True(t, response.Found)
+	assert.Equal(t, "5", response.MetricValue)
+	assert.Equal(t, &domain.HistogramValue{
+		Buckets: []domain.Bucket{
+			{UpperBound: 0.1, Count: 2},
+			{UpperBound: 0.5, Count: 3},
+			{UpperBound: 1, Count: 4},
+		},
+		Sum:   4.15,
+		Count: 5,
+	}, response.Histogram)
+
+	assert.ErrorIs(t, s.SetMetricValues(&domain.SetMetricsRequest{Metrics: []*domain.SetMetricRequest{
+		{MetricType: domain.Histogram, MetricName: "latency", MetricValue: "0.2"},
+		{MetricType: domain.Histogram, MetricName: "latency", MetricValue: "NaN"},
+	}}).Error, domain.ErrIncorrectMetricValue)
+	assert.ErrorIs(t, s.SetMetricValue(&domain.SetMetricRequest{
+		MetricType: domain.Histogram, MetricName: "latency", MetricValue: "+Inf",
+	}).Error, domain.ErrIncorrectMetricValue)
+	assert.Equal(t, uint64(5), s.GetMetricValue(&domain.MetricRequest{MetricName: "latency"}).Histogram.Count,
+		"a rejected batch observes nothing")
+
+	require.True(t, s.ResetMetric(&domain.MetricRequest{MetricName: "latency"}).Found)
+	all := s.GetAllMetrics(&domain.GetAllMetricsRequest{})
+	require.Len(t, all.Series, 1)
+	assert.Equal(t, "0", all.Series[0].Value)
+	assert.Equal(t, uint64(0), all.Series[0].Histogram.Count)
+	assert.Equal(t, uint64(0), all.Series[0].Histogram.Buckets[2].Count)
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+// Rollup aggregates the history of every series into the configured
+// resolutions up to the last bucket completed before req.Now, and drops
+// samples and rollups that are past their retention.
+func (s *MetricStorage) Rollup(req *domain.RollupRequest) *domain.RollupResponse {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for i, rollup := range s.rollups {
+		watermark := req.Now.Truncate(rollup.Resolution)
+		if !watermark.After(s.watermarks[i]) {
+			continue
+		}
+		for _, stored := range s.data {
+			if len(stored.rollups) < len(s.rollups) {
+				stored.rollups = append(stored.rollups, make([][]domain.Sample, len(s.rollups)-len(stored.rollups))...)
+			}
+			// Tier i is the source of rollups[i]: the raw samples for the
+			// first rollup, the previous rollup for the others.
+			source := s.history(stored, i, s.watermarks[i], watermark.Add(-time.Nanosecond))
+			stored.rollups[i] = append(stored.rollups[i], aggregate(source, rollup.Resolution)...)
+		}
+		s.watermarks[i] = watermark
+	}
+	for _, stored := range s.data {
+		stored.samples = trimSamples(stored.samples, req.Now.Add(-s.retention), len(stored.samples))
+		for i := range stored.rollups {
+			since := req.Now.Add(-s.rollups[i].Retention)
+			stored.rollups[i] = trimSamples(stored.rollups[i], since, len(stored.rollups[i]))
+		}
+	}
+	return &domain.RollupResponse{}
+}
+
+// tierFor picks the coarsest rollup whose resolution still fits in step.
+// Tier 0 is the raw samples, tier i+1 is rollups[i].
+func (s *MetricStorage) tierFor(step time.Duration) int {
+	tier := 0
+	for i, rollup := range s.rollups {
+		if rollup.Resolution <= step {
+			tier = i + 1
+		}
+	}
+	return tier
+}
+
+// history returns a copy of the samples of a tier between start and end.
+// Whatever has not been rolled up into the tier yet is aggregated on the fly
+// from the finer tiers, so recent data is never missing.
+func (s *MetricStorage) history(stored *entry, tier int, start, end time.Time) []domain.Sample {
+	if tier == 0 {
+		return between(stored.samples, start, end)
+	}
+	var samples []domain.Sample
+	if tier-1 < len(stored.rollups) {
+		samples = between(stored.rollups[tier-1], start, end)
+	}
+	watermark := s.watermarks[tier-1]
+	if end.Before(watermark) {
+		return samples
+	}
+	if start.Before(watermark) {
+		start = watermark
+	}
+	pending := aggregate(s.history(stored, tier-1, start, end), s.rollups[tier-1].Resolution)
+	return append(samples, pending...)
+}
+
+func between(samples []domain.Sample, start, end time.Time) []domain.Sample {
+	first := sort.Search(len(samples), func(i int) bool {
+		return !samples[i].Timestamp.Before(start)
+	})
+	last := sort.Search(len(samples), func(i int) bool {
+		return samples[i].Timestamp.After(end)
+	})
+	if first >= last {
+		return nil
+	}
+	return append([]domain.Sample(nil), samples[first:last]...)
+}
+
+// aggregate merges time ordered samples into buckets of the given
+// resolution, each stamped with the time its bucket starts.
+func aggregate(samples []domain.Sample, resolution time.Duration) []domain.Sample {
+	var buckets []domain.Sample
+	for _, sample := range samples {
+		bucket := sample.Timestamp.Truncate(resolution)
+		if n := len(buckets); n > 0 && buckets[n-1].Timestamp.Equal(bucket) {
+			buckets[n-1].Merge(sample)
+			continue
+		}
+		sample.Timestamp = bucket
+		buckets = append(buckets, sample)
+	}
+	return buckets
+}
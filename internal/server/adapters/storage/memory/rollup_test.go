@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+func TestMetricStorage_Rollup(t *testing.T) {
+	t0 := time.Date(2024, 4, 5, 10, 0, 0, 0, time.UTC)
+	now := t0
+	s := NewStorage(&Config{
+		Retention:  10 * time.Minute,
+		MaxSamples: 100,
+		Rollups: []Rollup{
+			{Resolution: time.Minute, Retention: time.Hour},
+			{Resolution: time.Hour, Retention: 24 * time.Hour},
+		},
+	})
+	s.now = func() time.Time { return now }
+	set := func(offset time.Duration, metricType, value string) {
+		now = t0.Add(offset)
+		response := s.SetMetricValue(&domain.SetMetricRequest{
+			MetricType:  metricType,
+			MetricName:  "m",
+			MetricValue: value,
+		})
+		require.NoError(t, response.Error)
+	}
+	query := func(step time.Duration) []domain.Sample {
+		response := s.GetMetricHistory(&domain.MetricHistoryRequest{
+			MetricName: "m",
+			Start:      t0,
+			End:        t0.Add(time.Hour),
+			Step:       step,
+		})
+		require.NoError(t, response.Error)
+		if len(response.Series) == 0 {
+			return nil
+		}
+		require.Len(t, response.Series, 1)
+		return response.Series[0].Samples
+	}
+
+	set(10*time.Second, domain.Gauge, "1")
+	set(20*time.Second, domain.Gauge, "3")
+	set(70*time.Second, domain.Gauge, "2")
+	require.NoError(t, s.Rollup(&domain.RollupRequest{Now: t0.Add(2*time.Minute + 5*time.Second)}).Error)
+	set(150*time.Second, domain.Gauge, "5")
+
+	assert.Len(t, query(0), 4)
+	assert.Len(t, query(30*time.Second), 4, "a step finer than every rollup uses raw samples")
+	assert.Equal(t, []domain.Sample{
+		{Timestamp: t0, Value: 3, Min: 1, Max: 3, Sum: 4, Count: 2},
+		{Timestamp: t0.Add(time.Minute), Value: 2, Min: 2, Max: 2, Sum: 2, Count: 1},
+		{Timestamp: t0.Add(2 * time.Minute), Value: 5, Min: 5, Max: 5, Sum: 5, Count: 1},
+	}, query(time.Minute), "samples after the watermark are rolled up on the fly")
+	assert.Equal(t, []domain.Sample{
+		{Timestamp: t0, Value: 5, Min: 1, Max: 5, Sum: 11, Count: 4},
+	}, query(time.Hour))
+
+	require.NoError(t, s.Rollup(&domain.RollupRequest{Now: t0.Add(20 * time.Minute)}).Error)
+	assert.Empty(t, query(0), "raw samples past retention are dropped")
+	assert.Len(t, query(time.Minute), 3, "rollups outlive raw samples")
+	assert.Equal(t, []domain.Sample{
+		{Timestamp: t0, Value: 5, Min: 1, Max: 5, Sum: 11, Count: 4},
+	}, query(time.Hour))
+}
+
+func TestMetricStorage_RollupCounters(t *testing.T) {
+	t0 := time.Date(2024, 4, 5, 10, 0, 0, 0, time.UTC)
+	now := t0
+	s := NewStorage(&Config{
+		Retention:  time.Hour,
+		MaxSamples: 100,
+		Rollups:    []Rollup{{Resolution: time.Minute, Retention: time.Hour}},
+	})
+	s.now = func() time.Time { return now }
+	for i, delta := range []string{"2", "3", "4"} {
+		now = t0.Add(time.Duration(i*20) * time.Second)
+		require.NoError(t, s.SetMetricValue(&domain.SetMetricRequest{
+			MetricType:  domain.Counter,
+			MetricName:  "requests",
+			MetricValue: delta,
+		}).Error)
+	}
+	require.NoError(t, s.Rollup(&domain.RollupRequest{Now: t0.Add(time.Minute)}).Error)
+	response := s.GetMetricHistory(&domain.MetricHistoryRequest{
+		MetricName: "requests",
+		Start:      t0,
+		End:        t0.Add(time.Hour),
+		Step:       time.Minute,
+	})
+	require.Len(t, response.Series, 1)
+	assert.Equal(t, []domain.Sample{
+		{Timestamp: t0, Value: 9, Min: 2, Max: 9, Sum: 9, Count: 3},
+	}, response.Series[0].Samples)
+}
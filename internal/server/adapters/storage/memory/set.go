@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+// parseSketch decodes the sketch of a set update, or returns nil when the
+// update adds a member. Only sketches with the precision of the stored ones
+// can be merged.
+func parseSketch(req *domain.SetMetricRequest) (*domain.HyperLogLog, error) {
+	if req.Sketch == nil {
+		if req.MetricValue == "" {
+			return nil, domain.ErrIncorrectMetricValue
+		}
+		return nil, nil
+	}
+	var sketch domain.HyperLogLog
+	if err := sketch.UnmarshalBinary(req.Sketch); err != nil {
+		return nil, err //nolint:wrapcheck // already a metric value error
+	}
+	if sketch.Precision() != domain.HLLPrecision {
+		return nil, fmt.Errorf("%w: expected a hyperloglog of precision %d, got %d",
+			domain.ErrIncorrectMetricValue, domain.HLLPrecision, sketch.Precision())
+	}
+	return &sketch, nil
+}
+
+// addToSet adds a member to a set or merges a sketch into it. The series value
+// is the estimated cardinality, which reads like a gauge in the history.
+func (s *MetricStorage) addToSet(req *domain.SetMetricRequest) *domain.SetMetricResponse {
+	sketch, err := parseSketch(req)
+	if err != nil {
+		return &domain.SetMetricResponse{
+			Error: err,
+		}
+	}
+	id := domain.SeriesID(req.MetricName, req.Labels)
+	var state *domain.HyperLogLog
+	if stored, found := s.lookup(id); found && stored.set != nil {
+		state = stored.set
+	} else if state, err = domain.NewHyperLogLog(domain.HLLPrecision); err != nil {
+		return &domain.SetMetricResponse{
+			Error: err,
+		}
+	}
+	if sketch != nil {
+		if err = state.Merge(sketch); err != nil {
+			return &domain.SetMetricResponse{
+				Error: err,
+			}
+		}
+	} else {
+		state.Add(req.MetricValue)
+	}
+	cardinality := state.Estimate()
+	s.set(req.MetricName, req.Labels, strconv.FormatUint(cardinality, 10), float64(cardinality))
+	s.data[id].set = state
+	return &domain.SetMetricResponse{
+		Error: nil,
+	}
+}
@@ -1,41 +1,147 @@
 package memory
 
 import (
+	"fmt"
+	"maps"
+	"sort"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
 )
 
+// MetricStorage keeps the latest value and the recent history of every
+// series, keyed by domain.SeriesID.
 type MetricStorage struct {
-	mux  *sync.Mutex
-	data map[string]string
+	mux        *sync.Mutex
+	data       map[string]*entry
+	retention  time.Duration
+	maxSamples int
+	rollups    []Rollup
+	staleAfter time.Duration
+	ttl        time.Duration
+	buckets    []float64
+	// compression is the t-digest compression of summaries.
+	compression float64
+	// watermarks[i] is the time before which every sample has been rolled up
+	// into rollups[i].
+	watermarks []time.Time
+	now        func() time.Time
+}
+
+type entry struct {
+	series    domain.Series
+	histogram *histogram
+	summary   *digest
+	set       *domain.HyperLogLog
+	samples   []domain.Sample
+	rollups   [][]domain.Sample
 }
 
 func NewStorage(cfg *Config) *MetricStorage {
+	buckets := cfg.Buckets
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	compression := cfg.Compression
+	if compression <= 0 {
+		compression = DefaultCompression
+	}
 	return &MetricStorage{
-		mux:  &sync.Mutex{},
-		data: make(map[string]string),
+		mux:         &sync.Mutex{},
+		data:        make(map[string]*entry),
+		retention:   cfg.Retention,
+		maxSamples:  cfg.MaxSamples,
+		rollups:     cfg.Rollups,
+		staleAfter:  cfg.StaleAfter,
+		ttl:         cfg.TTL,
+		buckets:     buckets,
+		compression: compression,
+		watermarks:  make([]time.Time, len(cfg.Rollups)),
+		now:         time.Now,
 	}
 }
 
 func (s *MetricStorage) GetMetricValue(req *domain.MetricRequest) *domain.MetricResponse {
 	s.mux.Lock()
 	defer s.mux.Unlock()
-	value, found := s.data[req.MetricName]
+	stored, found := s.lookup(domain.SeriesID(req.MetricName, req.Labels))
+	if !found {
+		return &domain.MetricResponse{}
+	}
 	return &domain.MetricResponse{
-		MetricValue: value,
-		Found:       found,
+		MetricValue: stored.series.Value,
+		Histogram:   stored.histogram.snapshot(s.buckets),
+		Summary:     stored.summary.snapshot(req.Quantiles),
+		Found:       true,
 	}
 }
 
 func (s *MetricStorage) SetMetricValue(req *domain.SetMetricRequest) *domain.SetMetricResponse {
 	s.mux.Lock()
 	defer s.mux.Unlock()
-	if req.MetricType == domain.Counter {
+	switch req.MetricType {
+	case domain.Counter:
 		return setCounterMetricValue(req, s)
+	case domain.Histogram:
+		return s.observe(req)
+	case domain.Summary:
+		return s.observeSummary(req)
+	case domain.Set:
+		return s.addToSet(req)
+	}
+	s.setGauge(req.MetricName, req.Labels, req.MetricValue)
+	return &domain.SetMetricResponse{
+		Error: nil,
+	}
+}
+
+func (s *MetricStorage) SetMetricValues(req *domain.SetMetricsRequest) *domain.SetMetricResponse {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	counters := make(map[string]int)
+	for _, metric := range req.Metrics {
+		var err error
+		switch metric.MetricType {
+		case domain.Counter:
+			err = s.checkCounter(metric, counters)
+		case domain.Histogram, domain.Summary:
+			_, err = parseObservation(metric.MetricValue)
+		case domain.Set:
+			_, err = parseSketch(metric)
+		}
+		if err != nil {
+			return &domain.SetMetricResponse{
+				Error: domain.ErrIncorrectMetricValue,
+			}
+		}
+	}
+	for _, metric := range req.Metrics {
+		switch metric.MetricType {
+		case domain.Counter:
+			if response := setCounterMetricValue(metric, s); response.Error != nil {
+				return response
+			}
+			continue
+		case domain.Histogram:
+			if response := s.observe(metric); response.Error != nil {
+				return response
+			}
+			continue
+		case domain.Summary:
+			if response := s.observeSummary(metric); response.Error != nil {
+				return response
+			}
+			continue
+		case domain.Set:
+			if response := s.addToSet(metric); response.Error != nil {
+				return response
+			}
+			continue
+		}
+		s.setGauge(metric.MetricName, metric.Labels, metric.MetricValue)
 	}
-	s.data[req.MetricName] = req.MetricValue
 	return &domain.SetMetricResponse{
 		Error: nil,
 	}
@@ -44,11 +150,215 @@ func (s *MetricStorage) SetMetricValue(req *domain.SetMetricRequest) *domain.Set
 func (s *MetricStorage) GetAllMetrics(req *domain.GetAllMetricsRequest) *domain.GetAllMetricsResponse {
 	s.mux.Lock()
 	defer s.mux.Unlock()
+	now := s.now()
+	ids := make([]string, 0, len(s.data))
+	for id, stored := range s.data {
+		if !s.expired(stored, now) && stored.series.Matches(req.Matchers) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	all := make([]*domain.Series, 0, len(ids))
+	for _, id := range ids {
+		series := s.data[id].series
+		series.Labels = maps.Clone(series.Labels)
+		series.Histogram = s.data[id].histogram.snapshot(s.buckets)
+		series.Summary = s.data[id].summary.snapshot(nil)
+		series.Stale = s.staleAfter > 0 && now.Sub(series.UpdatedAt) > s.staleAfter
+		all = append(all, &series)
+	}
 	return &domain.GetAllMetricsResponse{
-		Values: s.data,
+		Series: all,
+	}
+}
+
+func (s *MetricStorage) DeleteMetric(req *domain.MetricRequest) *domain.DeleteMetricResponse {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	id := domain.SeriesID(req.MetricName, req.Labels)
+	_, found := s.lookup(id)
+	delete(s.data, id)
+	return &domain.DeleteMetricResponse{
+		Found: found,
 	}
 }
 
+func (s *MetricStorage) ResetMetric(req *domain.MetricRequest) *domain.ResetMetricResponse {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	stored, found := s.lookup(domain.SeriesID(req.MetricName, req.Labels))
+	if found {
+		if stored.histogram != nil {
+			stored.histogram = newHistogram(s.buckets)
+		}
+		if stored.summary != nil {
+			stored.summary = newDigest(s.compression)
+		}
+		if stored.set != nil {
+			stored.set, _ = domain.NewHyperLogLog(domain.HLLPrecision)
+		}
+		s.set(stored.series.Name, stored.series.Labels, "0", 0)
+	}
+	return &domain.ResetMetricResponse{
+		Found: found,
+	}
+}
+
+// GetMetricHistory returns the samples of the matching series in time order,
+// taken from the coarsest resolution that fits the requested step. Series
+// without samples in the range are left out.
+func (s *MetricStorage) GetMetricHistory(req *domain.MetricHistoryRequest) *domain.MetricHistoryResponse {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := s.now()
+	ids := make([]string, 0)
+	for id, stored := range s.data {
+		if stored.series.Name == req.MetricName && !s.expired(stored, now) && stored.series.Matches(req.Matchers) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	tier := s.tierFor(req.Step)
+	history := make([]*domain.SeriesHistory, 0, len(ids))
+	for _, id := range ids {
+		stored := s.data[id]
+		samples := s.history(stored, tier, req.Start, req.End)
+		if len(samples) == 0 {
+			continue
+		}
+		history = append(history, &domain.SeriesHistory{
+			Name:    stored.series.Name,
+			Labels:  maps.Clone(stored.series.Labels),
+			Samples: samples,
+		})
+	}
+	return &domain.MetricHistoryResponse{
+		Series: history,
+	}
+}
+
+// Expire evicts the series that haven't been updated for longer than the TTL.
+func (s *MetricStorage) Expire(req *domain.ExpireRequest) *domain.ExpireResponse {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	expired := 0
+	for id, stored := range s.data {
+		if s.expired(stored, req.Now) {
+			delete(s.data, id)
+			expired++
+		}
+	}
+	return &domain.ExpireResponse{
+		Expired: expired,
+	}
+}
+
+// lookup finds a live series. Expired series are treated as missing even
+// before Expire evicts them, so an update starts them over.
+func (s *MetricStorage) lookup(id string) (*entry, bool) {
+	stored, found := s.data[id]
+	if !found || s.expired(stored, s.now()) {
+		return nil, false
+	}
+	return stored, true
+}
+
+func (s *MetricStorage) expired(stored *entry, now time.Time) bool {
+	return s.ttl > 0 && now.Sub(stored.series.UpdatedAt) > s.ttl
+}
+
+func (s *MetricStorage) setGauge(name string, labels domain.Labels, value string) {
+	gauge, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		gauge = 0
+	}
+	s.set(name, labels, value, gauge)
+}
+
+// set stores value for a series and appends it to its history with sum, the
+// gauge value itself or the counter increment. The labels are copied so
+// callers can't change the identity of a stored series afterwards.
+func (s *MetricStorage) set(name string, labels domain.Labels, value string, sum float64) {
+	id := domain.SeriesID(name, labels)
+	stored, found := s.lookup(id)
+	if !found {
+		stored = &entry{
+			series: domain.Series{
+				Name:   name,
+				Labels: maps.Clone(labels),
+			},
+		}
+		s.data[id] = stored
+	}
+	now := s.now()
+	stored.series.Value = value
+	stored.series.UpdatedAt = now
+	if s.retention <= 0 || s.maxSamples <= 0 {
+		return
+	}
+	sample, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return
+	}
+	stored.samples = append(stored.samples, domain.Sample{
+		Timestamp: now,
+		Value:     sample,
+		Min:       sample,
+		Max:       sample,
+		Sum:       sum,
+		Count:     1,
+	})
+	stored.samples = trimSamples(stored.samples, now.Add(-s.retention), s.maxSamples)
+}
+
+// trimSamples drops samples older than since and the oldest ones beyond
+// maxSamples.
+// The dropped head is released the next time append grows the slice.
+func trimSamples(samples []domain.Sample, since time.Time, maxSamples int) []domain.Sample {
+	drop := sort.Search(len(samples), func(i int) bool {
+		return !samples[i].Timestamp.Before(since)
+	})
+	if extra := len(samples) - drop - maxSamples; extra > 0 {
+		drop += extra
+	}
+	return samples[drop:]
+}
+
+// checkCounter fails when a counter delta of a batch isn't an integer or
+// would overflow the counter. totals holds the values the batch has brought
+// its counters to so far.
+func (s *MetricStorage) checkCounter(req *domain.SetMetricRequest, totals map[string]int) error {
+	delta, err := strconv.Atoi(req.MetricValue)
+	if err != nil {
+		return fmt.Errorf("%w: %w", domain.ErrIncorrectMetricValue, err)
+	}
+	id := domain.SeriesID(req.MetricName, req.Labels)
+	current, found := totals[id]
+	if !found {
+		if stored, found := s.lookup(id); found {
+			if current, err = strconv.Atoi(stored.series.Value); err != nil {
+				return fmt.Errorf("%w: %w", domain.ErrIncorrectMetricValue, err)
+			}
+		}
+	}
+	total, ok := addCounter(current, delta)
+	if !ok {
+		return domain.ErrIncorrectMetricValue
+	}
+	totals[id] = total
+	return nil
+}
+
+// addCounter adds delta to a counter and reports whether the sum fits in an
+// int.
+func addCounter(current, delta int) (int, bool) {
+	total := current + delta
+	if (delta > 0 && total < current) || (delta < 0 && total > current) {
+		return 0, false
+	}
+	return total, true
+}
+
 func setCounterMetricValue(req *domain.SetMetricRequest, s *MetricStorage) *domain.SetMetricResponse {
 	var currentValue int
 	newValue, err := strconv.Atoi(req.MetricValue)
@@ -57,9 +367,9 @@ func setCounterMetricValue(req *domain.SetMetricRequest, s *MetricStorage) *doma
 			Error: domain.ErrIncorrectMetricValue,
 		}
 	}
-	value, found := s.data[req.MetricName]
+	stored, found := s.lookup(domain.SeriesID(req.MetricName, req.Labels))
 	if found {
-		parsedValue, err := strconv.Atoi(value)
+		parsedValue, err := strconv.Atoi(stored.series.Value)
 		if err != nil {
 			return &domain.SetMetricResponse{
 				Error: domain.ErrIncorrectMetricValue,
@@ -67,8 +377,13 @@ func setCounterMetricValue(req *domain.SetMetricRequest, s *MetricStorage) *doma
 		}
 		currentValue = parsedValue
 	}
-	newValue += currentValue
-	s.data[req.MetricName] = strconv.Itoa(newValue)
+	total, ok := addCounter(currentValue, newValue)
+	if !ok {
+		return &domain.SetMetricResponse{
+			Error: domain.ErrIncorrectMetricValue,
+		}
+	}
+	s.set(req.MetricName, req.Labels, strconv.Itoa(total), float64(newValue))
 	return &domain.SetMetricResponse{
 		Error: nil,
 	}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+func TestMetricStorage_StaleAndExpire(t *testing.T) {
+	t0 := time.Date(2024, 4, 5, 10, 0, 0, 0, time.UTC)
+	now := t0
+	s := NewStorage(&Config{
+		StaleAfter: time.Minute,
+		TTL:        time.Hour,
+	})
+	s.now = func() time.Time { return now }
+	add := func(name, delta string) {
+		require.NoError(t, s.SetMetricValue(&domain.SetMetricRequest{
+			MetricType:  domain.Counter,
+			MetricName:  name,
+			MetricValue: delta,
+		}).Error)
+	}
+	get := func(name string) *domain.MetricResponse {
+		return s.GetMetricValue(&domain.MetricRequest{MetricType: domain.Counter, MetricName: name})
+	}
+	list := func() map[string]bool {
+		stale := make(map[string]bool)
+		for _, series := range s.GetAllMetrics(&domain.GetAllMetricsRequest{}).Series {
+			stale[series.Name] = series.Stale
+		}
+		return stale
+	}
+
+	add("old", "5")
+	now = t0.Add(30 * time.Minute)
+	add("fresh", "1")
+	assert.Equal(t, map[string]bool{"old": true, "fresh": false}, list())
+	assert.Equal(t, t0, s.GetAllMetrics(&domain.GetAllMetricsRequest{}).Series[1].UpdatedAt)
+
+	now = t0.Add(90 * time.Minute)
+	assert.Equal(t, map[string]bool{"fresh": true}, list(), "expired series are hidden")
+	assert.False(t, get("old").Found)
+	assert.Equal(t, 1, s.Expire(&domain.ExpireRequest{Now: now}).Expired)
+	assert.Equal(t, 0, s.Expire(&domain.ExpireRequest{Now: now}).Expired)
+
+	add("old", "2")
+	assert.Equal(t, "2", get("old").MetricValue, "an expired counter starts over")
+	assert.Equal(t, map[string]bool{"old": false, "fresh": true}, list())
+}
+
+func TestMetricStorage_CounterOverflow(t *testing.T) {
+	s := NewStorage(&Config{})
+	counter := func(name, delta string) *domain.SetMetricRequest {
+		return &domain.SetMetricRequest{MetricType: domain.Counter, MetricName: name, MetricValue: delta}
+	}
+	get := func(name string) string {
+		return s.GetMetricValue(&domain.MetricRequest{MetricType: domain.Counter, MetricName: name}).MetricValue
+	}
+	require.NoError(t, s.SetMetricValue(counter("a", "9223372036854775800")).Error)
+	assert.ErrorIs(t, s.SetMetricValue(counter("a", "8")).Error, domain.ErrIncorrectMetricValue)
+	assert.Equal(t, "9223372036854775800", get("a"))
+	require.NoError(t, s.SetMetricValue(counter("b", "-9223372036854775800")).Error)
+	assert.ErrorIs(t, s.SetMetricValue(counter("b", "-9")).Error, domain.ErrIncorrectMetricValue)
+
+	response := s.SetMetricValues(&domain.SetMetricsRequest{Metrics: []*domain.SetMetricRequest{
+		counter("c", "1"),
+		counter("a", "4"),
+		counter("a", "4"),
+	}})
+	assert.ErrorIs(t, response.Error, domain.ErrIncorrectMetricValue, "deltas of a batch add up")
+	assert.False(t, s.GetMetricValue(&domain.MetricRequest{MetricType: domain.Counter, MetricName: "c"}).Found,
+		"an overflowing batch stores nothing")
+	assert.Equal(t, "9223372036854775800", get("a"))
+}
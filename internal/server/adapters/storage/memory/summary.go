@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"strconv"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+// snapshot estimates the quantiles of d, DefaultQuantiles when there are
+// none, or returns nil for a series that isn't a summary. An empty summary
+// has no quantiles.
+func (d *digest) snapshot(quantiles []float64) *domain.SummaryValue {
+	if d == nil {
+		return nil
+	}
+	if len(quantiles) == 0 {
+		quantiles = DefaultQuantiles
+	}
+	value := &domain.SummaryValue{
+		Quantiles: make([]domain.Quantile, 0, len(quantiles)),
+		Sum:       d.sum,
+		Count:     d.count,
+	}
+	if d.count == 0 {
+		return value
+	}
+	for _, q := range quantiles {
+		value.Quantiles = append(value.Quantiles, domain.Quantile{
+			Quantile: q,
+			Value:    d.quantile(q),
+		})
+	}
+	return value
+}
+
+// observeSummary adds an observation to a summary. Like for histograms the
+// series value is the observation count.
+func (s *MetricStorage) observeSummary(req *domain.SetMetricRequest) *domain.SetMetricResponse {
+	observation, err := parseObservation(req.MetricValue)
+	if err != nil {
+		return &domain.SetMetricResponse{
+			Error: domain.ErrIncorrectMetricValue,
+		}
+	}
+	id := domain.SeriesID(req.MetricName, req.Labels)
+	state := newDigest(s.compression)
+	if stored, found := s.lookup(id); found && stored.summary != nil {
+		state = stored.summary
+	}
+	state.add(observation)
+	s.set(req.MetricName, req.Labels, strconv.FormatUint(state.count, 10), 1)
+	s.data[id].summary = state
+	return &domain.SetMetricResponse{
+		Error: nil,
+	}
+}
@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"math"
+	"sort"
+)
+
+// digest is a merging t-digest. Observations are buffered and periodically
+// merged into centroids whose weight is bounded by a scale function, so the
+// tails are kept at a finer resolution than the median. It holds at most
+// about compression centroids plus the buffer, however many observations it
+// has seen.
+type digest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	count       uint64
+	sum         float64
+	min         float64
+	max         float64
+}
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// bufferFactor sizes the buffer of unmerged observations relative to the
+// compression.
+const bufferFactor = 5
+
+func newDigest(compression float64) *digest {
+	return &digest{
+		compression: compression,
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+}
+
+func (d *digest) add(value float64) {
+	d.buffer = append(d.buffer, centroid{mean: value, weight: 1})
+	d.count++
+	d.sum += value
+	d.min = min(d.min, value)
+	d.max = max(d.max, value)
+	if float64(len(d.buffer)) >= bufferFactor*d.compression {
+		d.compress()
+	}
+}
+
+// compress merges the buffer into the centroids. A neighbour is folded into
+// the current centroid while the centroid spans at most one unit of the
+// arcsine scale k(q) = compression/2π·asin(2q-1). The scale is steep at the
+// tails, so centroids there stay small, and it spans compression/2 units in
+// total, which bounds the number of centroids.
+func (d *digest) compress() {
+	if len(d.buffer) == 0 {
+		return
+	}
+	all := append(d.centroids, d.buffer...)
+	d.buffer = d.buffer[:0]
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].mean < all[j].mean
+	})
+	total := float64(d.count)
+	merged := make([]centroid, 0, len(d.centroids)+1)
+	current := all[0]
+	var before float64
+	start := d.scale(0)
+	for _, next := range all[1:] {
+		weight := current.weight + next.weight
+		if d.scale((before+weight)/total)-start <= 1 {
+			current.mean += (next.mean - current.mean) * next.weight / weight
+			current.weight = weight
+			continue
+		}
+		before += current.weight
+		start = d.scale(before / total)
+		merged = append(merged, current)
+		current = next
+	}
+	d.centroids = append(merged, current)
+}
+
+func (d *digest) scale(q float64) float64 {
+	return d.compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+// quantile estimates the value below which a fraction q of the observations
+// fall, interpolating between centroid centers and the observed extremes.
+func (d *digest) quantile(q float64) float64 {
+	d.compress()
+	if len(d.centroids) == 0 {
+		return math.NaN()
+	}
+	if q <= 0 {
+		return d.min
+	}
+	if q >= 1 {
+		return d.max
+	}
+	index := q * float64(d.count)
+	first := d.centroids[0]
+	if index < first.weight/2 {
+		return d.min + (first.mean-d.min)*index/(first.weight/2)
+	}
+	center := first.weight / 2
+	for i := 1; i < len(d.centroids); i++ {
+		previous, next := d.centroids[i-1], d.centroids[i]
+		nextCenter := center + (previous.weight+next.weight)/2
+		if index <= nextCenter {
+			return previous.mean + (next.mean-previous.mean)*(index-center)/(nextCenter-center)
+		}
+		center = nextCenter
+	}
+	last := d.centroids[len(d.centroids)-1]
+	tail := float64(d.count) - center
+	return last.mean + (d.max-last.mean)*(index-center)/tail
+}
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigest_Quantile(t *testing.T) {
+	const n = 100000
+	d := newDigest(DefaultCompression)
+	rnd := rand.New(rand.NewSource(1))
+	for _, i := range rnd.Perm(n) {
+		d.add(float64(i + 1))
+	}
+	tests := []struct {
+		name     string
+		quantile float64
+		want     float64
+		delta    float64
+	}{
+		{name: "min", quantile: 0, want: 1},
+		{name: "median", quantile: 0.5, want: n / 2, delta: n * 0.01},
+		{name: "p90", quantile: 0.9, want: n * 0.9, delta: n * 0.005},
+		{name: "p99", quantile: 0.99, want: n * 0.99, delta: n * 0.001},
+		{name: "p999", quantile: 0.999, want: n * 0.999, delta: n * 0.0005},
+		{name: "max", quantile: 1, want: n},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, d.quantile(tt.quantile), tt.delta)
+		})
+	}
+	assert.Equal(t, uint64(n), d.count)
+	assert.LessOrEqual(t, len(d.centroids), 2*DefaultCompression, "memory is bounded by the compression")
+}
+
+func TestDigest_Small(t *testing.T) {
+	d := newDigest(DefaultCompression)
+	assert.True(t, math.IsNaN(d.quantile(0.5)))
+	d.add(3)
+	assert.InDelta(t, 3, d.quantile(0.5), 0)
+	d.add(1)
+	d.add(2)
+	assert.InDelta(t, 2, d.quantile(0.5), 1e-9)
+	assert.InDelta(t, 1, d.quantile(0), 0)
+	assert.InDelta(t, 3, d.quantile(1), 0)
+}
@@ -10,7 +10,13 @@ import (
 type MetricStorage interface {
 	GetMetricValue(request *domain.MetricRequest) *domain.MetricResponse
 	SetMetricValue(request *domain.SetMetricRequest) *domain.SetMetricResponse
+	SetMetricValues(request *domain.SetMetricsRequest) *domain.SetMetricResponse
 	GetAllMetrics(request *domain.GetAllMetricsRequest) *domain.GetAllMetricsResponse
+	DeleteMetric(request *domain.MetricRequest) *domain.DeleteMetricResponse
+	ResetMetric(request *domain.MetricRequest) *domain.ResetMetricResponse
+	GetMetricHistory(request *domain.MetricHistoryRequest) *domain.MetricHistoryResponse
+	Rollup(request *domain.RollupRequest) *domain.RollupResponse
+	Expire(request *domain.ExpireRequest) *domain.ExpireResponse
 }
 
 func NewStorage(conf Config) (MetricStorage, error) {
@@ -0,0 +1,16 @@
+package webhook
+
+import "time"
+
+// Config lists the URLs alert groups are posted to. A failed delivery is
+// retried Retries times, waiting Backoff before the first retry and twice as
+// long before each next one, up to MaxBackoff. QueueSize notifications per
+// URL wait while one is being delivered, later ones are dropped.
+type Config struct {
+	URLs       []string
+	Timeout    time.Duration
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	QueueSize  int
+}
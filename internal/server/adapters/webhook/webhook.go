@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+var (
+	errNoURLs    = errors.New("no webhook urls")
+	errQueueFull = errors.New("webhook queue is full")
+)
+
+// Sender posts alert groups as JSON to every configured URL. Each URL has
+// its own queue and worker, so a slow or failing endpoint doesn't hold up
+// the others.
+type Sender struct {
+	config *Config
+	client *http.Client
+	queues []chan []byte
+}
+
+func NewSender(cfg *Config) *Sender {
+	queues := make([]chan []byte, len(cfg.URLs))
+	for i := range queues {
+		queues[i] = make(chan []byte, cfg.QueueSize)
+	}
+	return &Sender{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		queues: queues,
+	}
+}
+
+// SendAlerts queues a group for delivery without waiting for it. A group that
+// doesn't fit in the queue of some URL is reported as an error.
+func (s *Sender) SendAlerts(req *domain.SendAlertsRequest) *domain.SendAlertsResponse {
+	body, err := json.Marshal(req.Group)
+	if err != nil {
+		return &domain.SendAlertsResponse{
+			Error: fmt.Errorf("failed to encode alert group %s: %w", req.Group.Group, err),
+		}
+	}
+	var errs []error
+	for i, queue := range s.queues {
+		select {
+		case queue <- body:
+		default:
+			errs = append(errs, fmt.Errorf("%w: dropped alert group %s for %s", errQueueFull, req.Group.Group,
+				s.config.URLs[i]))
+		}
+	}
+	return &domain.SendAlertsResponse{Error: errors.Join(errs...)}
+}
+
+// Run delivers the queued groups until ctx is done.
+func (s *Sender) Run(ctx context.Context) error {
+	if len(s.config.URLs) == 0 {
+		return errNoURLs
+	}
+	var wg sync.WaitGroup
+	for i, url := range s.config.URLs {
+		wg.Add(1)
+		go func(url string, queue <-chan []byte) {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case body := <-queue:
+					if err := s.deliver(ctx, url, body); err != nil {
+						log.Printf("failed to deliver alerts to %s: %v", url, err)
+					}
+				}
+			}
+		}(url, s.queues[i])
+	}
+	wg.Wait()
+	return nil
+}
+
+// deliver posts body to url, retrying with an exponential backoff on network
+// errors, 5xx and 429 responses.
+func (s *Sender) deliver(ctx context.Context, url string, body []byte) error {
+	backoff := s.config.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(ctx, url, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.config.Retries {
+			return err
+		}
+		log.Printf("retrying alerts for %s in %s: %v", url, backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("gave up after %d attempts: %w", attempt+1, ctx.Err())
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, s.config.MaxBackoff)
+	}
+}
+
+func (s *Sender) post(ctx context.Context, url string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to post alerts: %w", err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("failed to close webhook response body: %v", err)
+		}
+	}()
+	switch {
+	case resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return true, fmt.Errorf("webhook responded %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook rejected alerts: %s", resp.Status)
+	}
+}
@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+func newTestConfig(urls ...string) *Config {
+	return &Config{
+		URLs:       urls,
+		Timeout:    time.Second,
+		Retries:    3,
+		Backoff:    time.Millisecond,
+		MaxBackoff: 2 * time.Millisecond,
+		QueueSize:  10,
+	}
+}
+
+func TestSender_Deliver(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		wantErr  bool
+		attempts int32
+	}{
+		{name: "ok", statuses: []int{http.StatusOK}, attempts: 1},
+		{
+			name:     "retried",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusNoContent},
+			attempts: 3,
+		},
+		{name: "rejected", statuses: []int{http.StatusBadRequest}, wantErr: true, attempts: 1},
+		{name: "exhausted", statuses: []int{http.StatusBadGateway}, wantErr: true, attempts: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(attempts.Add(1))
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				w.WriteHeader(tt.statuses[min(n, len(tt.statuses))-1])
+			}))
+			defer srv.Close()
+			s := NewSender(newTestConfig(srv.URL))
+			err := s.deliver(context.Background(), srv.URL, []byte(`{}`))
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.attempts, attempts.Load())
+		})
+	}
+}
+
+func TestSender_SendAlerts(t *testing.T) {
+	received := make(chan *domain.AlertGroup, 2)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var group domain.AlertGroup
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&group))
+		received <- &group
+	})
+	up := httptest.NewServer(handler)
+	defer up.Close()
+	alsoUp := httptest.NewServer(handler)
+	defer alsoUp.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	s := NewSender(newTestConfig(down.URL, up.URL, alsoUp.URL))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	sentAt := time.Date(2024, 4, 5, 10, 0, 0, 0, time.UTC)
+	group := &domain.AlertGroup{
+		Group:  "HighCPU",
+		Status: domain.GroupFiring,
+		SentAt: sentAt,
+		Alerts: []*domain.Alert{{Rule: "HighCPU", Metric: "cpu", State: domain.AlertFiring, Value: 0.95}},
+	}
+	require.NoError(t, s.SendAlerts(&domain.SendAlertsRequest{Group: group}).Error)
+	for i := 0; i < 2; i++ {
+		select {
+		case got := <-received:
+			assert.Equal(t, group, got)
+		case <-time.After(time.Second):
+			t.Fatal("alerts weren't delivered")
+		}
+	}
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestSender_SendAlertsQueueFull(t *testing.T) {
+	cfg := newTestConfig("http://127.0.0.1:1")
+	cfg.QueueSize = 1
+	s := NewSender(cfg)
+	group := &domain.AlertGroup{Group: "HighCPU", Status: domain.GroupFiring}
+	require.NoError(t, s.SendAlerts(&domain.SendAlertsRequest{Group: group}).Error)
+	assert.ErrorIs(t, s.SendAlerts(&domain.SendAlertsRequest{Group: group}).Error, errQueueFull)
+}
+
+func TestSender_RunWithoutURLs(t *testing.T) {
+	assert.ErrorIs(t, NewSender(newTestConfig()).Run(context.Background()), errNoURLs)
+}
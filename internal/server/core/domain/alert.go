@@ -0,0 +1,185 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	AlertInactive = "inactive"
+	AlertPending  = "pending"
+	AlertFiring   = "firing"
+)
+
+const (
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpEqual        = "=="
+	OpNotEqual     = "!="
+)
+
+var ErrIncorrectAlertRule = errors.New("incorrect alert rule")
+
+// Duration is a time.Duration read from JSON as a Go duration string such as
+// "5m", or as a number of seconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String()) //nolint:wrapcheck // a string always marshals
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("bad duration %q: %w", s, err)
+		}
+		*d = Duration(parsed)
+		return nil
+	}
+	seconds, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("bad duration %s: %w", data, err)
+	}
+	*d = Duration(seconds * float64(time.Second))
+	return nil
+}
+
+// AlertRule compares the value of every series of Metric with the given
+// labels against Threshold. A series that keeps matching for For fires.
+type AlertRule struct {
+	Name       string   `json:"name"`
+	MetricType string   `json:"type"`
+	Metric     string   `json:"metric"`
+	Labels     Labels   `json:"labels,omitempty"`
+	Op         string   `json:"op"`
+	Threshold  float64  `json:"threshold"`
+	For        Duration `json:"for"`
+	Severity   string   `json:"severity"`
+}
+
+func (r *AlertRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("%w: missing name", ErrIncorrectAlertRule)
+	}
+	if r.Metric == "" {
+		return fmt.Errorf("%w %s: missing metric", ErrIncorrectAlertRule, r.Name)
+	}
+	switch r.MetricType {
+	case Gauge, Counter, Histogram, Summary, Set:
+	default:
+		return fmt.Errorf("%w %s: unknown metric type %q", ErrIncorrectAlertRule, r.Name, r.MetricType)
+	}
+	switch r.Op {
+	case OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpEqual, OpNotEqual:
+	default:
+		return fmt.Errorf("%w %s: unknown comparison %q", ErrIncorrectAlertRule, r.Name, r.Op)
+	}
+	if math.IsNaN(r.Threshold) {
+		return fmt.Errorf("%w %s: threshold is not a number", ErrIncorrectAlertRule, r.Name)
+	}
+	if r.For < 0 {
+		return fmt.Errorf("%w %s: negative for duration", ErrIncorrectAlertRule, r.Name)
+	}
+	if err := r.Labels.Validate(); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrIncorrectAlertRule, r.Name, err)
+	}
+	return nil
+}
+
+// Matches reports whether value breaches the threshold.
+func (r *AlertRule) Matches(value float64) bool {
+	switch r.Op {
+	case OpGreater:
+		return value > r.Threshold
+	case OpGreaterEqual:
+		return value >= r.Threshold
+	case OpLess:
+		return value < r.Threshold
+	case OpLessEqual:
+		return value <= r.Threshold
+	case OpEqual:
+		return value == r.Threshold
+	case OpNotEqual:
+		return value != r.Threshold
+	default:
+		return false
+	}
+}
+
+// ParseAlertRules reads a JSON list of rules and validates them. Rule names
+// must be unique.
+func ParseAlertRules(data []byte) ([]*AlertRule, error) {
+	var rules []*AlertRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrIncorrectAlertRule, err)
+	}
+	names := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("%w %s: duplicate name", ErrIncorrectAlertRule, rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return rules, nil
+}
+
+// Alert is the state of a rule for one series. ActiveAt is when the series
+// started to breach the threshold, it's unset while the alert is inactive.
+// ResolvedAt is only set in notifications, on alerts that stopped firing.
+type Alert struct {
+	Rule       string     `json:"rule"`
+	Severity   string     `json:"severity,omitempty"`
+	Metric     string     `json:"metric"`
+	Labels     Labels     `json:"labels,omitempty"`
+	State      string     `json:"state"`
+	Value      float64    `json:"value"`
+	Threshold  float64    `json:"threshold"`
+	ActiveAt   *time.Time `json:"active_at,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// GetAlertsRequest filters alerts by State, every alert is listed when it's
+// empty.
+type GetAlertsRequest struct {
+	State string
+}
+
+// GetAlertsResponse lists alerts sorted by rule and series.
+type GetAlertsResponse struct {
+	Alerts []*Alert
+	Error  error
+}
+
+const (
+	GroupFiring   = "firing"
+	GroupResolved = "resolved"
+)
+
+// AlertGroup is a notification about the alerts of one rule: the ones firing
+// and the ones resolved since the previous notification. Status is firing
+// while any alert of the group fires.
+type AlertGroup struct {
+	Group  string    `json:"group"`
+	Status string    `json:"status"`
+	SentAt time.Time `json:"sent_at"`
+	Alerts []*Alert  `json:"alerts"`
+}
+
+type SendAlertsRequest struct {
+	Group *AlertGroup
+}
+
+type SendAlertsResponse struct {
+	Error error
+}
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAlertRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []*AlertRule
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			data: `[{"name":"HighCPU","type":"gauge","metric":"cpu","labels":{"host":"web01"},` +
+				`"op":">","threshold":0.9,"for":"5m","severity":"critical"},` +
+				`{"name":"NoRequests","type":"counter","metric":"requests","op":"==","threshold":0,"for":30}]`,
+			want: []*AlertRule{
+				{
+					Name:       "HighCPU",
+					MetricType: Gauge,
+					Metric:     "cpu",
+					Labels:     Labels{"host": "web01"},
+					Op:         OpGreater,
+					Threshold:  0.9,
+					For:        Duration(5 * time.Minute),
+					Severity:   "critical",
+				},
+				{
+					Name:       "NoRequests",
+					MetricType: Counter,
+					Metric:     "requests",
+					Op:         OpEqual,
+					For:        Duration(30 * time.Second),
+				},
+			},
+		},
+		{name: "notJSON", data: `{`, wantErr: true},
+		{name: "badDuration", data: `[{"name":"a","type":"gauge","metric":"m","op":">","for":"soon"}]`, wantErr: true},
+		{name: "noName", data: `[{"type":"gauge","metric":"m","op":">"}]`, wantErr: true},
+		{name: "noMetric", data: `[{"name":"a","type":"gauge","op":">"}]`, wantErr: true},
+		{name: "badType", data: `[{"name":"a","type":"meter","metric":"m","op":">"}]`, wantErr: true},
+		{name: "badOp", data: `[{"name":"a","type":"gauge","metric":"m","op":"=>"}]`, wantErr: true},
+		{name: "negativeFor", data: `[{"name":"a","type":"gauge","metric":"m","op":">","for":"-1m"}]`, wantErr: true},
+		{name: "badLabel", data: `[{"name":"a","type":"gauge","metric":"m","op":">","labels":{"a b":"c"}}]`, wantErr: true},
+		{
+			name:    "duplicate",
+			data:    `[{"name":"a","type":"gauge","metric":"m","op":">"},{"name":"a","type":"gauge","metric":"n","op":"<"}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAlertRules([]byte(tt.data))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAlertRule_Matches(t *testing.T) {
+	tests := []struct {
+		op   string
+		want []bool
+	}{
+		{op: OpGreater, want: []bool{false, false, true}},
+		{op: OpGreaterEqual, want: []bool{false, true, true}},
+		{op: OpLess, want: []bool{true, false, false}},
+		{op: OpLessEqual, want: []bool{true, true, false}},
+		{op: OpEqual, want: []bool{false, true, false}},
+		{op: OpNotEqual, want: []bool{true, false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.op, func(t *testing.T) {
+			rule := &AlertRule{Op: tt.op, Threshold: 1}
+			for i, value := range []float64{0, 1, 2} {
+				assert.Equal(t, tt.want[i], rule.Matches(value), "value %v", value)
+			}
+		})
+	}
+}
@@ -1,10 +1,16 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 const (
-	Gauge   = "gauge"
-	Counter = "counter"
+	Gauge     = "gauge"
+	Counter   = "counter"
+	Histogram = "histogram"
+	Summary   = "summary"
+	Set       = "set"
 )
 
 var (
@@ -13,32 +19,140 @@ var (
 	ErrItemNotFound         = errors.New("item not found")
 )
 
+// MetricRequest addresses a series. Quantiles are the ones estimated for a
+// summary, storages fall back to their defaults when there are none.
 type MetricRequest struct {
 	MetricType string
 	MetricName string
+	Labels     Labels
+	Quantiles  []float64
 }
 
+// MetricResponse carries the value of a series. For histograms and summaries
+// MetricValue is the observation count, and Histogram or Summary holds the
+// distribution.
 type MetricResponse struct {
 	MetricValue string
+	Histogram   *HistogramValue
+	Summary     *SummaryValue
 	Found       bool
 	Error       error
 }
 
+// SetMetricRequest updates a series. For a set MetricValue is a member to
+// add, or Sketch is an encoded HyperLogLog to merge.
 type SetMetricRequest struct {
 	MetricType  string
 	MetricName  string
 	MetricValue string
+	Sketch      []byte
+	Labels      Labels
+}
+
+type SetMetricsRequest struct {
+	Metrics []*SetMetricRequest
 }
 
 type SetMetricResponse struct {
 	Error error
 }
 
+type DeleteMetricResponse struct {
+	Found bool
+	Error error
+}
+
+type ResetMetricResponse struct {
+	Found bool
+	Error error
+}
+
 type GetAllMetricsRequest struct {
 	MetricType string
+	Matchers   []*LabelMatcher
 }
 
+// GetAllMetricsResponse lists the matching series sorted by SeriesID.
 type GetAllMetricsResponse struct {
-	Values map[string]string
+	Series []*Series
 	Error  error
 }
+
+// Sample is a point of history. A raw sample has Count 1, a rollup
+// aggregates every sample of its interval. Value is the last value, Sum adds
+// up gauge values or counter increments.
+type Sample struct {
+	Timestamp time.Time
+	Value     float64
+	Min       float64
+	Max       float64
+	Sum       float64
+	Count     int64
+}
+
+// Merge folds a later sample into s.
+func (s *Sample) Merge(later Sample) {
+	s.Value = later.Value
+	s.Min = min(s.Min, later.Min)
+	s.Max = max(s.Max, later.Max)
+	s.Sum += later.Sum
+	s.Count += later.Count
+}
+
+type RollupRequest struct {
+	Now time.Time
+}
+
+type RollupResponse struct {
+	Error error
+}
+
+// MetricHistoryRequest selects the samples of every series called MetricName
+// that satisfies Matchers between Start and End inclusive. A positive Step
+// asks for one sample per step instead of the raw ones, and lets storages
+// answer from rollups no coarser than Step.
+type MetricHistoryRequest struct {
+	MetricType string
+	MetricName string
+	Matchers   []*LabelMatcher
+	Start      time.Time
+	End        time.Time
+	Step       time.Duration
+}
+
+type SeriesHistory struct {
+	Name    string
+	Labels  Labels
+	Samples []Sample
+}
+
+type MetricHistoryResponse struct {
+	Series []*SeriesHistory
+	Error  error
+}
+
+type ExpireRequest struct {
+	Now time.Time
+}
+
+type ExpireResponse struct {
+	Expired int
+	Error   error
+}
+
+// Metrics is the JSON form of a metric. Histograms and summaries are updated
+// with one observation in Value and read back as Histogram or Summary. Sets
+// are updated with a Member or a base64 encoded HyperLogLog Sketch and read
+// back as their estimated Cardinality.
+type Metrics struct {
+	ID          string          `json:"id"`
+	MType       string          `json:"type"`
+	Delta       *int64          `json:"delta,omitempty"`
+	Value       *float64        `json:"value,omitempty"`
+	Histogram   *HistogramValue `json:"histogram,omitempty"`
+	Summary     *SummaryValue   `json:"summary,omitempty"`
+	Member      *string         `json:"member,omitempty"`
+	Sketch      []byte          `json:"sketch,omitempty"`
+	Cardinality *uint64         `json:"cardinality,omitempty"`
+	Labels      Labels          `json:"labels,omitempty"`
+}
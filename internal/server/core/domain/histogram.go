@@ -0,0 +1,30 @@
+package domain
+
+// Bucket counts the observations less than or equal to UpperBound. Counts
+// are cumulative, like the le buckets of Prometheus.
+type Bucket struct {
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"`
+}
+
+// HistogramValue is a snapshot of a histogram. The +Inf bucket isn't listed,
+// its count is Count.
+type HistogramValue struct {
+	Buckets []Bucket `json:"buckets"`
+	Sum     float64  `json:"sum"`
+	Count   uint64   `json:"count"`
+}
+
+// Quantile is the estimated value below which a fraction Quantile of the
+// observations fall.
+type Quantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+// SummaryValue is a snapshot of a summary with the requested quantiles.
+type SummaryValue struct {
+	Quantiles []Quantile `json:"quantiles"`
+	Sum       float64    `json:"sum"`
+	Count     uint64     `json:"count"`
+}
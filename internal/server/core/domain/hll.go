@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// HLLPrecision is the precision of the sketches behind sets: 2^14 one byte
+// registers, for a standard error of about 0.8%.
+const HLLPrecision = 14
+
+const (
+	minHLLPrecision = 4
+	maxHLLPrecision = 18
+)
+
+// HyperLogLog estimates the number of distinct members added to it in a fixed
+// amount of memory. Members are hashed with 64-bit FNV-1a and a MurmurHash3
+// finalizer, so sketches built by different processes can be merged.
+type HyperLogLog struct {
+	precision uint8
+	registers []uint8
+}
+
+func NewHyperLogLog(precision uint8) (*HyperLogLog, error) {
+	if precision < minHLLPrecision || precision > maxHLLPrecision {
+		return nil, fmt.Errorf("%w: hyperloglog precision %d is out of range", ErrIncorrectMetricValue, precision)
+	}
+	return &HyperLogLog{
+		precision: precision,
+		registers: make([]uint8, 1<<precision),
+	}, nil
+}
+
+func (h *HyperLogLog) Precision() uint8 {
+	return h.precision
+}
+
+func (h *HyperLogLog) Add(member string) {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(member))
+	x := mix64(hash.Sum64())
+	index := x >> (64 - h.precision)
+	// The guard bit caps the rank when the remaining bits are all zero.
+	rank := uint8(bits.LeadingZeros64(x<<h.precision|1<<(h.precision-1))) + 1
+	h.registers[index] = max(h.registers[index], rank)
+}
+
+// Merge folds other into h, after which h estimates the cardinality of the
+// union of both. The sketches must have the same precision.
+func (h *HyperLogLog) Merge(other *HyperLogLog) error {
+	if other.precision != h.precision {
+		return fmt.Errorf("%w: can't merge a hyperloglog of precision %d into %d",
+			ErrIncorrectMetricValue, other.precision, h.precision)
+	}
+	for i, rank := range other.registers {
+		h.registers[i] = max(h.registers[i], rank)
+	}
+	return nil
+}
+
+// Estimate returns the estimated cardinality, with linear counting for the
+// small ones where the raw estimate is biased.
+func (h *HyperLogLog) Estimate() uint64 {
+	m := float64(len(h.registers))
+	var sum float64
+	zeros := 0
+	for _, rank := range h.registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// MarshalBinary encodes the sketch as its precision followed by one byte per
+// register.
+func (h *HyperLogLog) MarshalBinary() ([]byte, error) {
+	return append([]byte{h.precision}, h.registers...), nil
+}
+
+func (h *HyperLogLog) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("%w: empty hyperloglog", ErrIncorrectMetricValue)
+	}
+	sketch, err := NewHyperLogLog(data[0])
+	if err != nil {
+		return err
+	}
+	if len(data)-1 != len(sketch.registers) {
+		return fmt.Errorf("%w: hyperloglog of precision %d has %d registers",
+			ErrIncorrectMetricValue, sketch.precision, len(data)-1)
+	}
+	for _, rank := range data[1:] {
+		if int(rank) > 64-int(sketch.precision)+1 {
+			return fmt.Errorf("%w: hyperloglog register %d is out of range", ErrIncorrectMetricValue, rank)
+		}
+	}
+	copy(sketch.registers, data[1:])
+	*h = *sketch
+	return nil
+}
+
+// mix64 is the MurmurHash3 finalizer. It spreads the FNV hash over all bits,
+// which the register index and the rank both rely on.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
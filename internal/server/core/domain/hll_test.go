@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHyperLogLog_Estimate(t *testing.T) {
+	tests := []struct {
+		name    string
+		members int
+	}{
+		{name: "empty", members: 0},
+		{name: "one", members: 1},
+		{name: "small", members: 1000},
+		{name: "large", members: 200000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sketch, err := NewHyperLogLog(HLLPrecision)
+			require.NoError(t, err)
+			for i := 0; i < tt.members; i++ {
+				sketch.Add("user-" + strconv.Itoa(i))
+				sketch.Add("user-" + strconv.Itoa(i))
+			}
+			assert.InEpsilon(t, float64(tt.members)+1, float64(sketch.Estimate())+1, 0.03)
+		})
+	}
+}
+
+func TestHyperLogLog_Merge(t *testing.T) {
+	a, err := NewHyperLogLog(HLLPrecision)
+	require.NoError(t, err)
+	b, err := NewHyperLogLog(HLLPrecision)
+	require.NoError(t, err)
+	union, err := NewHyperLogLog(HLLPrecision)
+	require.NoError(t, err)
+	for i := 0; i < 30000; i++ {
+		member := "host-" + strconv.Itoa(i)
+		if i < 20000 {
+			a.Add(member)
+		}
+		if i >= 10000 {
+			b.Add(member)
+		}
+		union.Add(member)
+	}
+	require.NoError(t, a.Merge(b))
+	assert.Equal(t, union.Estimate(), a.Estimate(), "merging is the same as adding the union")
+
+	other, err := NewHyperLogLog(HLLPrecision - 1)
+	require.NoError(t, err)
+	assert.ErrorIs(t, a.Merge(other), ErrIncorrectMetricValue)
+}
+
+func TestHyperLogLog_Binary(t *testing.T) {
+	sketch, err := NewHyperLogLog(HLLPrecision)
+	require.NoError(t, err)
+	sketch.Add("alice")
+	sketch.Add("bob")
+	data, err := sketch.MarshalBinary()
+	require.NoError(t, err)
+	var decoded HyperLogLog
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, sketch, &decoded)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "badPrecision", data: []byte{30, 0}},
+		{name: "short", data: data[:len(data)-1]},
+		{name: "badRegister", data: append([]byte{4}, append(make([]byte, 15), 64)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, decoded.UnmarshalBinary(tt.data), ErrIncorrectMetricValue)
+		})
+	}
+}
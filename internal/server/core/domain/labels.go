@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricNameLabel is the pseudo label matchers use to select on the metric
+// name. It can't be set on a series.
+const MetricNameLabel = "__name__"
+
+const (
+	MatchEqual     = "="
+	MatchNotEqual  = "!="
+	MatchRegexp    = "=~"
+	MatchNotRegexp = "!~"
+)
+
+var (
+	ErrIncorrectLabels       = fmt.Errorf("%w: incorrect labels", ErrIncorrectMetricValue)
+	ErrIncorrectLabelMatcher = errors.New("incorrect label matcher")
+)
+
+// Labels are the key/value dimensions of a series. Two metrics with the same
+// name but different labels are different series.
+type Labels map[string]string
+
+// String returns the labels in the Prometheus notation {k="v",...} sorted by
+// key, or an empty string when there are none.
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(l))
+	for key := range l {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(l[key]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Validate rejects label names that are empty, reserved or contain characters
+// used by the series notation.
+func (l Labels) Validate() error {
+	for key := range l {
+		if key == "" || key == MetricNameLabel || strings.ContainsAny(key, "{}=,\"!~ \t\n") {
+			return fmt.Errorf("%w: bad label name %q", ErrIncorrectLabels, key)
+		}
+	}
+	return nil
+}
+
+// ValidateMetricName rejects metric names containing characters of the label
+// notation, so that a name can't pose as another series in its SeriesID.
+func ValidateMetricName(name string) error {
+	if strings.ContainsAny(name, "{}\",=") {
+		return fmt.Errorf("%w: bad metric name %q", ErrIncorrectMetricValue, name)
+	}
+	return nil
+}
+
+// SeriesID identifies a series by its name and labels.
+func SeriesID(name string, labels Labels) string {
+	return name + labels.String()
+}
+
+// Series is the latest state of a series. Stale is set when it hasn't been
+// updated for a while but hasn't expired yet. Histogram and Summary are only
+// set for their metric types.
+type Series struct {
+	Name      string
+	Labels    Labels
+	Value     string
+	Histogram *HistogramValue
+	Summary   *SummaryValue
+	UpdatedAt time.Time
+	Stale     bool
+}
+
+// LabelMatcher selects series by one label, with the same semantics as a
+// Prometheus selector: a missing label matches as an empty value.
+type LabelMatcher struct {
+	Type  string
+	Name  string
+	Value string
+	re    *regexp.Regexp
+}
+
+func NewLabelMatcher(matchType, name, value string) (*LabelMatcher, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: missing label name", ErrIncorrectLabelMatcher)
+	}
+	matcher := &LabelMatcher{
+		Type:  matchType,
+		Name:  name,
+		Value: value,
+	}
+	switch matchType {
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrIncorrectLabelMatcher, err)
+		}
+		matcher.re = re
+	default:
+		return nil, fmt.Errorf("%w: unknown match type %q", ErrIncorrectLabelMatcher, matchType)
+	}
+	return matcher, nil
+}
+
+func (m *LabelMatcher) Matches(value string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	default:
+		return false
+	}
+}
+
+// Matches reports whether the series satisfies every matcher.
+func (s *Series) Matches(matchers []*LabelMatcher) bool {
+	for _, matcher := range matchers {
+		value := s.Labels[matcher.Name]
+		if matcher.Name == MetricNameLabel {
+			value = s.Name
+		}
+		if !matcher.Matches(value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+// AlertService evaluates alert rules against the stored metrics. Every series
+// a rule selects gets an alert that is inactive while the threshold holds,
+// pending once it's breached and firing when it stays breached for the rule's
+// for duration.
+type AlertService struct {
+	metricService *MetricService
+	rules         []*domain.AlertRule
+	mux           *sync.Mutex
+	// alerts are keyed by rule name and series ID.
+	alerts         map[string]*domain.Alert
+	sender         AlertSender
+	repeatInterval time.Duration
+	// notified holds, per rule, the firing alerts of the last notification.
+	notified map[string]*notifiedGroup
+}
+
+// AlertSender delivers notifications about alert groups. It shouldn't block
+// the evaluation while it retries. A notification it can't accept is reported
+// as an error and sent again on the next evaluation.
+type AlertSender interface {
+	SendAlerts(request *domain.SendAlertsRequest) *domain.SendAlertsResponse
+}
+
+type notifiedGroup struct {
+	alerts map[string]*domain.Alert
+	sentAt time.Time
+}
+
+// AlertOption configures the notifications of an AlertService.
+type AlertOption func(*AlertService)
+
+// WithAlertSender sends a group of alerts whenever one of them starts firing
+// or is resolved, and again every repeatInterval while any of them fires.
+// A zero repeatInterval never repeats.
+func WithAlertSender(sender AlertSender, repeatInterval time.Duration) AlertOption {
+	return func(as *AlertService) {
+		as.sender = sender
+		as.repeatInterval = repeatInterval
+	}
+}
+
+func NewAlertService(metricService *MetricService, rules []*domain.AlertRule, opts ...AlertOption) *AlertService {
+	as := &AlertService{
+		metricService: metricService,
+		rules:         rules,
+		mux:           &sync.Mutex{},
+		alerts:        make(map[string]*domain.Alert),
+		notified:      make(map[string]*notifiedGroup),
+	}
+	for _, opt := range opts {
+		opt(as)
+	}
+	return as
+}
+
+// Evaluate moves every alert to its state at now. Alerts of series that are
+// gone are dropped. A rule that can't be evaluated keeps its alerts as they
+// were, and its error is returned once the other rules are done.
+func (as *AlertService) Evaluate(now time.Time) error {
+	as.mux.Lock()
+	defer as.mux.Unlock()
+	var errs []error
+	for _, rule := range as.rules {
+		if err := as.evaluate(rule, now); err != nil {
+			errs = append(errs, fmt.Errorf("failed to evaluate alert rule %s: %w", rule.Name, err))
+		}
+		if as.sender != nil {
+			if err := as.notify(rule, now); err != nil {
+				errs = append(errs, fmt.Errorf("failed to notify alert rule %s: %w", rule.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// notify sends the group of a rule when its firing alerts changed since the
+// last notification, or when the repeat interval is up while some still fire.
+// Alerts that stopped firing, or whose series are gone, are sent once as
+// resolved.
+func (as *AlertService) notify(rule *domain.AlertRule, now time.Time) error {
+	last, found := as.notified[rule.Name]
+	if !found {
+		last = &notifiedGroup{alerts: make(map[string]*domain.Alert)}
+		as.notified[rule.Name] = last
+	}
+	firing := make(map[string]*domain.Alert)
+	for key, alert := range as.alerts {
+		if alert.Rule == rule.Name && alert.State == domain.AlertFiring {
+			firing[key] = alert
+		}
+	}
+	changed := len(firing) != len(last.alerts)
+	for key := range firing {
+		if _, found := last.alerts[key]; !found {
+			changed = true
+		}
+	}
+	repeat := len(firing) > 0 && as.repeatInterval > 0 && now.Sub(last.sentAt) >= as.repeatInterval
+	if !changed && !repeat {
+		return nil
+	}
+	group := &domain.AlertGroup{
+		Group:  rule.Name,
+		Status: domain.GroupResolved,
+		SentAt: now,
+	}
+	if len(firing) > 0 {
+		group.Status = domain.GroupFiring
+	}
+	keys := make([]string, 0, len(firing)+len(last.alerts))
+	for key := range firing {
+		keys = append(keys, key)
+	}
+	for key := range last.alerts {
+		if _, found := firing[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if alert, found := firing[key]; found {
+			group.Alerts = append(group.Alerts, copyAlert(alert))
+			continue
+		}
+		resolved := copyAlert(last.alerts[key])
+		resolved.State = domain.AlertInactive
+		resolved.ResolvedAt = &now
+		if alert, found := as.alerts[key]; found {
+			resolved.Value = alert.Value
+		}
+		group.Alerts = append(group.Alerts, resolved)
+	}
+	if err := as.sender.SendAlerts(&domain.SendAlertsRequest{Group: group}).Error; err != nil {
+		return err
+	}
+	last.alerts = make(map[string]*domain.Alert, len(firing))
+	for key, alert := range firing {
+		last.alerts[key] = copyAlert(alert)
+	}
+	last.sentAt = now
+	return nil
+}
+
+func copyAlert(alert *domain.Alert) *domain.Alert {
+	c := *alert
+	c.Labels = maps.Clone(alert.Labels)
+	if alert.ActiveAt != nil {
+		activeAt := *alert.ActiveAt
+		c.ActiveAt = &activeAt
+	}
+	return &c
+}
+
+func (as *AlertService) evaluate(rule *domain.AlertRule, now time.Time) error {
+	matchers, err := ruleMatchers(rule)
+	if err != nil {
+		return err
+	}
+	response := as.metricService.GetAllMetrics(&domain.GetAllMetricsRequest{
+		MetricType: rule.MetricType,
+		Matchers:   matchers,
+	})
+	if response.Error != nil {
+		return response.Error
+	}
+	seen := make(map[string]bool, len(response.Series))
+	for _, series := range response.Series {
+		value, err := strconv.ParseFloat(series.Value, 64)
+		if err != nil {
+			log.Printf("skipping series %s of alert rule %s: %v", series.Name, rule.Name, err)
+			continue
+		}
+		key := rule.Name + " " + domain.SeriesID(series.Name, series.Labels)
+		seen[key] = true
+		alert, found := as.alerts[key]
+		if !found {
+			alert = &domain.Alert{
+				Rule:      rule.Name,
+				Severity:  rule.Severity,
+				Metric:    series.Name,
+				Labels:    maps.Clone(series.Labels),
+				State:     domain.AlertInactive,
+				Threshold: rule.Threshold,
+			}
+			as.alerts[key] = alert
+		}
+		alert.Value = value
+		if !rule.Matches(value) {
+			alert.State = domain.AlertInactive
+			alert.ActiveAt = nil
+			continue
+		}
+		if alert.ActiveAt == nil {
+			activeAt := now
+			alert.ActiveAt = &activeAt
+			alert.State = domain.AlertPending
+		}
+		if now.Sub(*alert.ActiveAt) >= time.Duration(rule.For) {
+			alert.State = domain.AlertFiring
+		}
+	}
+	for key, alert := range as.alerts {
+		if alert.Rule == rule.Name && !seen[key] {
+			delete(as.alerts, key)
+		}
+	}
+	return nil
+}
+
+func ruleMatchers(rule *domain.AlertRule) ([]*domain.LabelMatcher, error) {
+	matcher, err := domain.NewLabelMatcher(domain.MatchEqual, domain.MetricNameLabel, rule.Metric)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // already a matcher error
+	}
+	matchers := []*domain.LabelMatcher{matcher}
+	for name, value := range rule.Labels {
+		if matcher, err = domain.NewLabelMatcher(domain.MatchEqual, name, value); err != nil {
+			return nil, err //nolint:wrapcheck // already a matcher error
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
+
+func (as *AlertService) GetAlerts(request *domain.GetAlertsRequest) *domain.GetAlertsResponse {
+	as.mux.Lock()
+	defer as.mux.Unlock()
+	keys := make([]string, 0, len(as.alerts))
+	for key, alert := range as.alerts {
+		if request.State == "" || alert.State == request.State {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	alerts := make([]*domain.Alert, 0, len(keys))
+	for _, key := range keys {
+		alerts = append(alerts, copyAlert(as.alerts[key]))
+	}
+	return &domain.GetAlertsResponse{
+		Alerts: alerts,
+	}
+}
+
+// RunEvaluation evaluates the rules every interval until ctx is done.
+func (as *AlertService) RunEvaluation(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("alert evaluation interval must be positive, got %s", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			if err := as.Evaluate(now); err != nil {
+				log.Printf("failed to evaluate alerts: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agatma/sprint1-http-server/internal/server/adapters/storage/memory"
+	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
+)
+
+func TestAlertService_Evaluate(t *testing.T) {
+	metricService := NewMetricService(memory.NewStorage(&memory.Config{}), memory.NewStorage(&memory.Config{}))
+	setCPU := func(host, value string) {
+		require.NoError(t, metricService.SetMetricValue(&domain.SetMetricRequest{
+			MetricType:  domain.Gauge,
+			MetricName:  "cpu",
+			MetricValue: value,
+			Labels:      domain.Labels{"host": host},
+		}).Error)
+	}
+	alertService := NewAlertService(metricService, []*domain.AlertRule{{
+		Name:       "HighCPU",
+		MetricType: domain.Gauge,
+		Metric:     "cpu",
+		Op:         domain.OpGreater,
+		Threshold:  0.9,
+		For:        domain.Duration(time.Minute),
+		Severity:   "critical",
+	}})
+	states := func() map[string]string {
+		states := make(map[string]string)
+		for _, alert := range alertService.GetAlerts(&domain.GetAlertsRequest{}).Alerts {
+			states[alert.Labels["host"]] = alert.State
+		}
+		return states
+	}
+	t0 := time.Date(2024, 4, 5, 10, 0, 0, 0, time.UTC)
+
+	setCPU("web01", "0.5")
+	setCPU("web02", "0.95")
+	require.NoError(t, alertService.Evaluate(t0))
+	assert.Equal(t, map[string]string{"web01": domain.AlertInactive, "web02": domain.AlertPending}, states())
+
+	require.NoError(t, alertService.Evaluate(t0.Add(30*time.Second)))
+	assert.Equal(t, map[string]string{"web01": domain.AlertInactive, "web02": domain.AlertPending}, states())
+
+	setCPU("web01", "0.99")
+	require.NoError(t, alertService.Evaluate(t0.Add(time.Minute)))
+	assert.Equal(t, map[string]string{"web01": domain.AlertPending, "web02": domain.AlertFiring}, states())
+	firing := alertService.GetAlerts(&domain.GetAlertsRequest{State: domain.AlertFiring}).Alerts
+	require.Len(t, firing, 1)
+	assert.Equal(t, &domain.Alert{
+		Rule:      "HighCPU",
+		Severity:  "critical",
+		Metric:    "cpu",
+		Labels:    domain.Labels{"host": "web02"},
+		State:     domain.AlertFiring,
+		Value:     0.95,
+		Threshold: 0.9,
+		ActiveAt:  &t0,
+	}, firing[0])
+
+	setCPU("web02", "0.5")
+	require.NoError(t, alertService.Evaluate(t0.Add(2*time.Minute)))
+	assert.Equal(t, map[string]string{"web01": domain.AlertFiring, "web02": domain.AlertInactive}, states())
+
+	metricService.DeleteMetric(&domain.MetricRequest{
+		MetricType: domain.Gauge,
+		MetricName: "cpu",
+		Labels:     domain.Labels{"host": "web01"},
+	})
+	require.NoError(t, alertService.Evaluate(t0.Add(3*time.Minute)))
+	assert.Equal(t, map[string]string{"web02": domain.AlertInactive}, states(), "alerts of deleted series are dropped")
+}
+
+func TestAlertService_EvaluateLabels(t *testing.T) {
+	metricService := NewMetricService(memory.NewStorage(&memory.Config{}), memory.NewStorage(&memory.Config{}))
+	for _, path := range []string{"/a", "/b"} {
+		require.NoError(t, metricService.SetMetricValue(&domain.SetMetricRequest{
+			MetricType:  domain.Counter,
+			MetricName:  "errors",
+			MetricValue: "10",
+			Labels:      domain.Labels{"path": path},
+		}).Error)
+	}
+	alertService := NewAlertService(metricService, []*domain.AlertRule{{
+		Name:       "Errors",
+		MetricType: domain.Counter,
+		Metric:     "errors",
+		Labels:     domain.Labels{"path": "/a"},
+		Op:         domain.OpGreaterEqual,
+		Threshold:  10,
+	}})
+	require.NoError(t, alertService.Evaluate(time.Now()))
+	alerts := alertService.GetAlerts(&domain.GetAlertsRequest{}).Alerts
+	require.Len(t, alerts, 1)
+	assert.Equal(t, domain.Labels{"path": "/a"}, alerts[0].Labels)
+	assert.Equal(t, domain.AlertFiring, alerts[0].State, "a rule without a for duration fires at once")
+}
+
+type recordingSender struct {
+	groups []*domain.AlertGroup
+	// err rejects every notification while it's set.
+	err error
+}
+
+func (s *recordingSender) SendAlerts(request *domain.SendAlertsRequest) *domain.SendAlertsResponse {
+	if s.err != nil {
+		return &domain.SendAlertsResponse{Error: s.err}
+	}
+	s.groups = append(s.groups, request.Group)
+	return &domain.SendAlertsResponse{}
+}
+
+func TestAlertService_Notify(t *testing.T) {
+	metricService := NewMetricService(memory.NewStorage(&memory.Config{}), memory.NewStorage(&memory.Config{}))
+	setCPU := func(host, value string) {
+		require.NoError(t, metricService.SetMetricValue(&domain.SetMetricRequest{
+			MetricType:  domain.Gauge,
+			MetricName:  "cpu",
+			MetricValue: value,
+			Labels:      domain.Labels{"host": host},
+		}).Error)
+	}
+	sender := &recordingSender{}
+	alertService := NewAlertService(metricService, []*domain.AlertRule{{
+		Name:       "HighCPU",
+		MetricType: domain.Gauge,
+		Metric:     "cpu",
+		Op:         domain.OpGreater,
+		Threshold:  0.9,
+	}}, WithAlertSender(sender, time.Hour))
+	t0 := time.Date(2024, 4, 5, 10, 0, 0, 0, time.UTC)
+	// sent evaluates at t0+offset and returns the notification it sent, if
+	// any, as the group status and the host and state of every alert.
+	sent := func(offset time.Duration) []string {
+		before := len(sender.groups)
+		require.NoError(t, alertService.Evaluate(t0.Add(offset)))
+		if len(sender.groups) == before {
+			return nil
+		}
+		require.Len(t, sender.groups, before+1)
+		group := sender.groups[before]
+		assert.Equal(t, "HighCPU", group.Group)
+		summary := []string{group.Status}
+		for _, alert := range group.Alerts {
+			summary = append(summary, alert.Labels["host"]+" "+alert.State)
+		}
+		return summary
+	}
+
+	setCPU("web01", "0.5")
+	assert.Nil(t, sent(0), "nothing fires")
+
+	setCPU("web01", "0.95")
+	assert.Equal(t, []string{domain.GroupFiring, "web01 firing"}, sent(time.Minute))
+	assert.Nil(t, sent(2*time.Minute), "still firing within the repeat interval")
+
+	setCPU("web02", "0.99")
+	assert.Equal(t, []string{domain.GroupFiring, "web01 firing", "web02 firing"}, sent(3*time.Minute),
+		"alerts of a rule are grouped")
+
+	setCPU("web01", "0.5")
+	assert.Equal(t, []string{domain.GroupFiring, "web01 inactive", "web02 firing"}, sent(4*time.Minute))
+	resolved := sender.groups[len(sender.groups)-1].Alerts[0]
+	require.NotNil(t, resolved.ResolvedAt)
+	assert.Equal(t, t0.Add(4*time.Minute), *resolved.ResolvedAt)
+	assert.InDelta(t, 0.5, resolved.Value, 0)
+
+	assert.Nil(t, sent(time.Hour))
+	assert.Equal(t, []string{domain.GroupFiring, "web02 firing"}, sent(time.Hour+4*time.Minute),
+		"firing alerts are repeated")
+
+	metricService.DeleteMetric(&domain.MetricRequest{
+		MetricType: domain.Gauge,
+		MetricName: "cpu",
+		Labels:     domain.Labels{"host": "web02"},
+	})
+	assert.Equal(t, []string{domain.GroupResolved, "web02 inactive"}, sent(2*time.Hour),
+		"alerts of deleted series are resolved")
+	assert.Nil(t, sent(4*time.Hour), "resolved groups aren't repeated")
+}
+
+func TestAlertService_NotifyRejected(t *testing.T) {
+	metricService := NewMetricService(memory.NewStorage(&memory.Config{}), memory.NewStorage(&memory.Config{}))
+	require.NoError(t, metricService.SetMetricValue(&domain.SetMetricRequest{
+		MetricType:  domain.Gauge,
+		MetricName:  "cpu",
+		MetricValue: "0.95",
+	}).Error)
+	errRejected := errors.New("queue is full")
+	sender := &recordingSender{err: errRejected}
+	alertService := NewAlertService(metricService, []*domain.AlertRule{{
+		Name:       "HighCPU",
+		MetricType: domain.Gauge,
+		Metric:     "cpu",
+		Op:         domain.OpGreater,
+		Threshold:  0.9,
+	}}, WithAlertSender(sender, time.Hour))
+	t0 := time.Date(2024, 4, 5, 10, 0, 0, 0, time.UTC)
+
+	assert.ErrorIs(t, alertService.Evaluate(t0), errRejected)
+	assert.Empty(t, sender.groups)
+
+	sender.err = nil
+	require.NoError(t, alertService.Evaluate(t0.Add(time.Minute)))
+	require.Len(t, sender.groups, 1, "a rejected notification is sent again")
+	assert.Equal(t, domain.GroupFiring, sender.groups[0].Status)
+	require.NoError(t, alertService.Evaluate(t0.Add(2*time.Minute)))
+	assert.Len(t, sender.groups, 1)
+}
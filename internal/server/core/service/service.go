@@ -1,7 +1,12 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"math"
 	"strconv"
+	"time"
 
 	"github.com/agatma/sprint1-http-server/internal/server/core/domain"
 )
@@ -9,62 +14,306 @@ import (
 type MetricStorage interface {
 	GetMetricValue(request *domain.MetricRequest) *domain.MetricResponse
 	SetMetricValue(request *domain.SetMetricRequest) *domain.SetMetricResponse
+	SetMetricValues(request *domain.SetMetricsRequest) *domain.SetMetricResponse
 	GetAllMetrics(request *domain.GetAllMetricsRequest) *domain.GetAllMetricsResponse
+	DeleteMetric(request *domain.MetricRequest) *domain.DeleteMetricResponse
+	ResetMetric(request *domain.MetricRequest) *domain.ResetMetricResponse
+	GetMetricHistory(request *domain.MetricHistoryRequest) *domain.MetricHistoryResponse
+	Rollup(request *domain.RollupRequest) *domain.RollupResponse
+	Expire(request *domain.ExpireRequest) *domain.ExpireResponse
 }
 
 type MetricService struct {
-	gaugeStorage   MetricStorage
-	counterStorage MetricStorage
+	gaugeStorage     MetricStorage
+	counterStorage   MetricStorage
+	histogramStorage MetricStorage
+	summaryStorage   MetricStorage
+	setStorage       MetricStorage
 }
 
-func NewMetricService(gauge MetricStorage, counter MetricStorage) *MetricService {
-	return &MetricService{
+// Option configures the optional storages of a MetricService. Metric types
+// without a storage are rejected on update and listed as empty.
+type Option func(*MetricService)
+
+func WithHistogramStorage(histogram MetricStorage) Option {
+	return func(ms *MetricService) {
+		ms.histogramStorage = histogram
+	}
+}
+
+func WithSummaryStorage(summary MetricStorage) Option {
+	return func(ms *MetricService) {
+		ms.summaryStorage = summary
+	}
+}
+
+func WithSetStorage(set MetricStorage) Option {
+	return func(ms *MetricService) {
+		ms.setStorage = set
+	}
+}
+
+func NewMetricService(gauge MetricStorage, counter MetricStorage, opts ...Option) *MetricService {
+	ms := &MetricService{
 		gaugeStorage:   gauge,
 		counterStorage: counter,
 	}
+	for _, opt := range opts {
+		opt(ms)
+	}
+	return ms
 }
 
-func (ms *MetricService) GetMetricValue(request *domain.MetricRequest) *domain.MetricResponse {
-	switch request.MetricType {
+// storageFor returns the storage of a metric type, or nil when the type is
+// unknown or has no storage.
+func (ms *MetricService) storageFor(metricType string) MetricStorage {
+	switch metricType {
 	case domain.Gauge:
-		return ms.gaugeStorage.GetMetricValue(request)
+		return ms.gaugeStorage
 	case domain.Counter:
-		return ms.counterStorage.GetMetricValue(request)
+		return ms.counterStorage
+	case domain.Histogram:
+		return ms.histogramStorage
+	case domain.Summary:
+		return ms.summaryStorage
+	case domain.Set:
+		return ms.setStorage
 	default:
+		return nil
+	}
+}
+
+func (ms *MetricService) storages() []MetricStorage {
+	storages := []MetricStorage{ms.gaugeStorage, ms.counterStorage}
+	for _, storage := range []MetricStorage{ms.histogramStorage, ms.summaryStorage, ms.setStorage} {
+		if storage != nil {
+			storages = append(storages, storage)
+		}
+	}
+	return storages
+}
+
+// validateSeries checks the name and labels that make up the series identity.
+func validateSeries(request *domain.SetMetricRequest) error {
+	if err := domain.ValidateMetricName(request.MetricName); err != nil {
+		return err //nolint:wrapcheck // domain error
+	}
+	return request.Labels.Validate() //nolint:wrapcheck // domain error
+}
+
+func validateMetricValue(request *domain.SetMetricRequest) error {
+	var err error
+	value := request.MetricValue
+	switch request.MetricType {
+	case domain.Gauge, domain.Histogram, domain.Summary:
+		// JSON has no encoding for NaN or infinities, and a single one would
+		// poison the sum of a histogram or a summary for good.
+		var number float64
+		if number, err = strconv.ParseFloat(value, 64); err == nil && (math.IsNaN(number) || math.IsInf(number, 0)) {
+			err = domain.ErrIncorrectMetricValue
+		}
+	case domain.Counter:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case domain.Set:
+		if request.Sketch != nil {
+			var sketch domain.HyperLogLog
+			err = sketch.UnmarshalBinary(request.Sketch)
+		} else if value == "" {
+			err = domain.ErrIncorrectMetricValue
+		}
+	}
+	if err != nil {
+		return domain.ErrIncorrectMetricValue
+	}
+	return nil
+}
+
+func (ms *MetricService) GetMetricValue(request *domain.MetricRequest) *domain.MetricResponse {
+	storage := ms.storageFor(request.MetricType)
+	if storage == nil {
 		return &domain.MetricResponse{
 			Error: domain.ErrIncorrectMetricType,
 		}
 	}
+	return storage.GetMetricValue(request)
 }
 
 func (ms *MetricService) SetMetricValue(request *domain.SetMetricRequest) *domain.SetMetricResponse {
-	switch request.MetricType {
-	case domain.Gauge:
-		_, err := strconv.ParseFloat(request.MetricValue, 64)
-		if err != nil {
-			return &domain.SetMetricResponse{
-				Error: domain.ErrIncorrectMetricValue,
-			}
+	if err := validateSeries(request); err != nil {
+		return &domain.SetMetricResponse{
+			Error: err,
 		}
-		return ms.gaugeStorage.SetMetricValue(request)
-	case domain.Counter:
-		return ms.counterStorage.SetMetricValue(request)
-	default:
+	}
+	storage := ms.storageFor(request.MetricType)
+	if storage == nil {
 		return &domain.SetMetricResponse{
 			Error: domain.ErrIncorrectMetricType,
 		}
 	}
+	if err := validateMetricValue(request); err != nil {
+		return &domain.SetMetricResponse{
+			Error: err,
+		}
+	}
+	return storage.SetMetricValue(request)
+}
+
+// SetMetricValues stores a batch of metrics. The batch is validated as a unit:
+// every metric is checked before any storage is touched, so a malformed item
+// rejects the whole batch. The storages are then updated one after another,
+// so a reader may briefly see part of a batch, and a storage that fails
+// leaves the ones updated before it as they are.
+func (ms *MetricService) SetMetricValues(request *domain.SetMetricsRequest) *domain.SetMetricResponse {
+	batches := make(map[MetricStorage]*domain.SetMetricsRequest)
+	for _, metric := range request.Metrics {
+		if err := validateSeries(metric); err != nil {
+			return &domain.SetMetricResponse{
+				Error: err,
+			}
+		}
+		storage := ms.storageFor(metric.MetricType)
+		if storage == nil {
+			return &domain.SetMetricResponse{
+				Error: domain.ErrIncorrectMetricType,
+			}
+		}
+		if err := validateMetricValue(metric); err != nil {
+			return &domain.SetMetricResponse{
+				Error: err,
+			}
+		}
+		if batches[storage] == nil {
+			batches[storage] = &domain.SetMetricsRequest{}
+		}
+		batches[storage].Metrics = append(batches[storage].Metrics, metric)
+	}
+	for _, storage := range ms.storages() {
+		if batch, found := batches[storage]; found {
+			if response := storage.SetMetricValues(batch); response.Error != nil {
+				return response
+			}
+		}
+	}
+	return &domain.SetMetricResponse{
+		Error: nil,
+	}
 }
 
+// GetAllMetrics lists the series of a metric type. A known type without a
+// storage has none.
 func (ms *MetricService) GetAllMetrics(request *domain.GetAllMetricsRequest) *domain.GetAllMetricsResponse {
-	switch request.MetricType {
-	case domain.Gauge:
-		return ms.gaugeStorage.GetAllMetrics(request)
-	case domain.Counter:
-		return ms.counterStorage.GetAllMetrics(request)
-	default:
+	storage := ms.storageFor(request.MetricType)
+	if storage == nil {
+		switch request.MetricType {
+		case domain.Histogram, domain.Summary, domain.Set:
+			return &domain.GetAllMetricsResponse{}
+		}
 		return &domain.GetAllMetricsResponse{
 			Error: domain.ErrIncorrectMetricType,
 		}
 	}
+	return storage.GetAllMetrics(request)
+}
+
+func (ms *MetricService) DeleteMetric(request *domain.MetricRequest) *domain.DeleteMetricResponse {
+	storage := ms.storageFor(request.MetricType)
+	if storage == nil {
+		return &domain.DeleteMetricResponse{
+			Error: domain.ErrIncorrectMetricType,
+		}
+	}
+	return storage.DeleteMetric(request)
+}
+
+// ResetMetric sets a counter back to zero. Gauges have no meaningful reset
+// value and are rejected.
+func (ms *MetricService) ResetMetric(request *domain.MetricRequest) *domain.ResetMetricResponse {
+	if request.MetricType != domain.Counter {
+		return &domain.ResetMetricResponse{
+			Error: domain.ErrIncorrectMetricType,
+		}
+	}
+	return ms.counterStorage.ResetMetric(request)
+}
+
+func (ms *MetricService) GetMetricHistory(request *domain.MetricHistoryRequest) *domain.MetricHistoryResponse {
+	storage := ms.storageFor(request.MetricType)
+	if storage == nil {
+		return &domain.MetricHistoryResponse{
+			Error: domain.ErrIncorrectMetricType,
+		}
+	}
+	response := storage.GetMetricHistory(request)
+	if response.Error != nil || request.Step <= 0 {
+		return response
+	}
+	for _, series := range response.Series {
+		series.Samples = alignSamples(series.Samples, request.Start, request.Step)
+	}
+	return response
+}
+
+// alignSamples merges the samples of every step long bucket counted from
+// start into one stamped with the beginning of its bucket.
+func alignSamples(samples []domain.Sample, start time.Time, step time.Duration) []domain.Sample {
+	aligned := make([]domain.Sample, 0, len(samples))
+	for _, sample := range samples {
+		bucket := start.Add(sample.Timestamp.Sub(start) / step * step)
+		if n := len(aligned); n > 0 && aligned[n-1].Timestamp.Equal(bucket) {
+			aligned[n-1].Merge(sample)
+			continue
+		}
+		sample.Timestamp = bucket
+		aligned = append(aligned, sample)
+	}
+	return aligned
+}
+
+// RunRollups rolls the history of every storage up every interval until ctx
+// is done.
+func (ms *MetricService) RunRollups(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("rollup interval must be positive, got %s", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			for _, storage := range ms.storages() {
+				if response := storage.Rollup(&domain.RollupRequest{Now: now}); response.Error != nil {
+					log.Printf("failed to roll up history: %v", response.Error)
+				}
+			}
+		}
+	}
+}
+
+// RunJanitor evicts expired series from every storage every interval until
+// ctx is done.
+func (ms *MetricService) RunJanitor(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("janitor interval must be positive, got %s", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			for _, storage := range ms.storages() {
+				response := storage.Expire(&domain.ExpireRequest{Now: now})
+				if response.Error != nil {
+					log.Printf("failed to expire metrics: %v", response.Error)
+					continue
+				}
+				if response.Expired > 0 {
+					log.Printf("expired %d metrics", response.Expired)
+				}
+			}
+		}
+	}
 }